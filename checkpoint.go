@@ -0,0 +1,244 @@
+package main
+
+import (
+	json2 "encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Checkpoint describes the progress of an indexing run, to resume it
+// if it has been interrupted. The sentences are indexed in the order
+// of their IDs, so the progress is the number of sentences acknowledged.
+type Checkpoint struct {
+	mutex sync.Mutex
+	path  string
+
+	// Fingerprint of the input files the sentences have been parsed from.
+	Fingerprint string `json:"fingerprint"`
+	// Engine and index being built.
+	Engine     string `json:"engine"`
+	BuildIndex string `json:"build_index"`
+	// Number of sentences acknowledged by the engine, and the
+	// number of them which failed.
+	Acknowledged int `json:"acknowledged"`
+	Failed       int `json:"failed"`
+}
+
+// NewCheckpoint create the checkpoint of a new run.
+func NewCheckpoint(path, engine, fingerprint string) *Checkpoint {
+	return &Checkpoint{
+		path:        path,
+		Engine:      engine,
+		Fingerprint: fingerprint,
+	}
+}
+
+// ReadCheckpoint read the checkpoint of an interrupted run.
+func ReadCheckpoint(path string) (*Checkpoint, error) {
+	content, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the checkpoint: %s", err)
+	}
+
+	checkpoint := &Checkpoint{path: path}
+
+	if err := json2.Unmarshal(content, checkpoint); err != nil {
+		return nil, fmt.Errorf("cannot decode the checkpoint: %s", err)
+	}
+
+	return checkpoint, nil
+}
+
+// SnapshotPath returns the path of the parsed sentences of the run.
+func (c *Checkpoint) SnapshotPath() string {
+	return c.path + ".snapshot"
+}
+
+// Acknowledge set the number of sentences acknowledged by the engine
+// and save the checkpoint.
+func (c *Checkpoint) Acknowledge(acknowledged, failed int) error {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.Acknowledged = acknowledged
+	c.Failed = failed
+
+	return c.save()
+}
+
+// SetBuildIndex set the index being built and save the checkpoint.
+func (c *Checkpoint) SetBuildIndex(index string) error {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.BuildIndex = index
+
+	return c.save()
+}
+
+// save write the checkpoint, through a temporary file
+// to never leave a truncated checkpoint.
+func (c *Checkpoint) save() error {
+	content, err := json2.Marshal(c)
+
+	if err != nil {
+		return fmt.Errorf("cannot encode the checkpoint: %s", err)
+	}
+
+	if err := ioutil.WriteFile(c.path+".tmp", content, 0644); err != nil {
+		return fmt.Errorf("cannot write the checkpoint: %s", err)
+	}
+
+	if err := os.Rename(c.path+".tmp", c.path); err != nil {
+		return fmt.Errorf("cannot write the checkpoint: %s", err)
+	}
+
+	return nil
+}
+
+// Remove delete the checkpoint and the snapshot once the run succeeded.
+func (c *Checkpoint) Remove() {
+	if c == nil {
+		return
+	}
+
+	_ = os.Remove(c.path)
+	_ = os.Remove(c.SnapshotPath())
+}
+
+// SortedIDs returns the IDs of the sentences sorted numerically,
+// the order used to index the sentences.
+func SortedIDs(sentences map[string]Sentence) []string {
+	numericIDs := make([]int, 0, len(sentences))
+
+	for _, sentence := range sentences {
+		numericIDs = append(numericIDs, int(sentence.ID))
+	}
+
+	sort.Ints(numericIDs)
+
+	IDs := make([]string, len(numericIDs))
+
+	for i, ID := range numericIDs {
+		IDs[i] = strconv.Itoa(ID)
+	}
+
+	return IDs
+}
+
+// checkpointChunkSize is the number of sentences the checkpoint
+// moves forward by when the sentences are acknowledged out of order.
+const checkpointChunkSize = 1000
+
+// chunkTracker track the sentences acknowledged out of order, like by
+// the workers of the Elasticsearch bulk indexer, by chunks of sentences.
+// The checkpoint is saved when the chunks are complete in order.
+type chunkTracker struct {
+	mutex      sync.Mutex
+	checkpoint *Checkpoint
+	start      int
+	total      int
+	chunkSize  int
+	remaining  []int
+	failed     []int
+	next       int
+	// Failed sentences of the complete chunks, with the ones
+	// of the previous runs.
+	totalFailed int
+	// First failure saving the checkpoint, the workers
+	// acknowledging the sentences can't return it.
+	err error
+}
+
+// newChunkTracker create a tracker of the sentences from the start
+// position, which is the number of sentences already acknowledged.
+func newChunkTracker(checkpoint *Checkpoint, start, total, chunkSize int) *chunkTracker {
+	chunks := (total - start + chunkSize - 1) / chunkSize
+	tracker := &chunkTracker{
+		checkpoint: checkpoint,
+		start:      start,
+		total:      total,
+		chunkSize:  chunkSize,
+		remaining:  make([]int, chunks),
+		failed:     make([]int, chunks),
+	}
+
+	if checkpoint != nil {
+		tracker.totalFailed = checkpoint.Failed
+	}
+
+	for i := range tracker.remaining {
+		tracker.remaining[i] = chunkSize
+	}
+
+	// The last chunk can be smaller.
+	if chunks > 0 && (total-start)%chunkSize != 0 {
+		tracker.remaining[chunks-1] = (total - start) % chunkSize
+	}
+
+	return tracker
+}
+
+// Done mark the sentence at the given position as acknowledged.
+func (t *chunkTracker) Done(position int, failed bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	chunk := (position - t.start) / t.chunkSize
+	t.remaining[chunk]--
+
+	if failed {
+		t.failed[chunk]++
+	}
+
+	// Move forward while the chunks are complete.
+	advanced := false
+
+	for t.next < len(t.remaining) && t.remaining[t.next] == 0 {
+		t.totalFailed += t.failed[t.next]
+		t.next++
+		advanced = true
+	}
+
+	if advanced {
+		acknowledged := t.start + t.next*t.chunkSize
+
+		if acknowledged > t.total {
+			acknowledged = t.total
+		}
+
+		if err := t.checkpoint.Acknowledge(acknowledged, t.totalFailed); err != nil && t.err == nil {
+			t.err = err
+		}
+	}
+}
+
+// Failed returns the number of failed sentences of the complete
+// chunks, including the ones of the interrupted runs.
+func (t *chunkTracker) Failed() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.totalFailed
+}
+
+// Err returns the first failure saving the checkpoint.
+func (t *chunkTracker) Err() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.err
+}
@@ -0,0 +1,636 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/integrii/flaggy"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigPath is the configuration file used when it exists
+// and no other file is given.
+const defaultConfigPath = "tatoeba.yaml"
+
+// environmentPrefix is the prefix of the environment variables
+// overriding the flags, like TATOEBA_INDEX or TATOEBA_MEILISEARCH_HOST.
+const environmentPrefix = "TATOEBA_"
+
+// promptFlags are the flags asking for a secret on the terminal, they
+// aren't read from the environment: their variables would clash with
+// the ones giving the secrets, like TATOEBA_ELASTICSEARCH_PASSWORD.
+var promptFlags = map[string]bool{"api-key": true, "password": true}
+
+// Config describes the YAML configuration file.
+type Config struct {
+	ConfigSettings `yaml:",inline"`
+	// Targets are the search engines to index, by name.
+	Targets map[string]Target `yaml:"targets"`
+	// Profiles override the settings and select the targets, by name.
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// ConfigSettings describes the settings a profile can override.
+type ConfigSettings struct {
+	// Options are the global flags by their long name, like "verify".
+	Options    map[string]interface{} `yaml:"options"`
+	Source     SourceConfig           `yaml:"source"`
+	Filters    FiltersConfig          `yaml:"filters"`
+	Enrichment EnrichmentConfig       `yaml:"enrichment"`
+}
+
+// SourceConfig describes where the sentences come from.
+type SourceConfig struct {
+	// Download the files even if they exist.
+	Download *bool `yaml:"download"`
+	// Snapshot of parsed sentences to index instead of the files.
+	Snapshot *string `yaml:"snapshot"`
+}
+
+// FiltersConfig describes the sentences to index.
+type FiltersConfig struct {
+	// Languages of the sentences, all the languages when empty.
+	Languages []string `yaml:"languages"`
+}
+
+// EnrichmentConfig describes the data added to the sentences.
+type EnrichmentConfig struct {
+	Audio             *bool `yaml:"audio"`
+	IndirectRelations *bool `yaml:"indirect-relations"`
+	Transcriptions    *bool `yaml:"transcriptions"`
+}
+
+// Profile describes a set of settings and targets, like "dev" or "production".
+type Profile struct {
+	ConfigSettings `yaml:",inline"`
+	// Targets used by the profile, all the targets when empty.
+	Targets []string `yaml:"targets"`
+}
+
+// loadedConfig is the configuration file used, if any.
+var loadedConfig *Config
+
+// LoadConfig read the configuration file given by --config or the
+// TATOEBA_CONFIG environment variable, or the default one if it exists.
+// It returns nil when there is no configuration file.
+func LoadConfig() *Config {
+	if configPath == "" {
+		configPath = os.Getenv(environmentPrefix + "CONFIG")
+	}
+
+	if configPath == "" {
+		if !FileExists(defaultConfigPath) {
+			return nil
+		}
+
+		configPath = defaultConfigPath
+	}
+
+	// Select the profile and the target from the environment
+	// when they are not given by the flags.
+	if profileName == "" {
+		profileName = os.Getenv(environmentPrefix + "PROFILE")
+	}
+
+	if targetName == "" {
+		targetName = os.Getenv(environmentPrefix + "TARGET")
+	}
+
+	content, err := ioutil.ReadFile(configPath)
+
+	if err != nil {
+		log.Fatalf("Cannot read the configuration file: %s", err)
+	}
+
+	// Reject the unknown keys, they are probably typos.
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	config := &Config{}
+
+	if err := decoder.Decode(config); err != nil && err != io.EOF {
+		log.Fatalf("Cannot decode the configuration file \"%s\": %s", configPath, err)
+	}
+
+	for name, target := range config.Targets {
+		target.Name = name
+		config.Targets[name] = target
+	}
+
+	loadedConfig = config
+
+	return config
+}
+
+// flagOptions returns the settings as the global flags they set.
+func (s ConfigSettings) flagOptions() map[string]interface{} {
+	options := make(map[string]interface{})
+
+	for key, value := range s.Options {
+		options[key] = value
+	}
+
+	if s.Source.Download != nil {
+		options["download-files"] = *s.Source.Download
+	}
+
+	if s.Source.Snapshot != nil {
+		options["from-snapshot"] = *s.Source.Snapshot
+	}
+
+	if s.Filters.Languages != nil {
+		options["languages"] = strings.Join(s.Filters.Languages, ",")
+	}
+
+	if s.Enrichment.Audio != nil {
+		options["skip-audio"] = !*s.Enrichment.Audio
+	}
+
+	if s.Enrichment.IndirectRelations != nil {
+		options["skip-indirect-relations"] = !*s.Enrichment.IndirectRelations
+	}
+
+	if s.Enrichment.Transcriptions != nil {
+		options["skip-transcriptions"] = !*s.Enrichment.Transcriptions
+	}
+
+	return options
+}
+
+// resolve returns the global flags set by the file and the profile,
+// and the targets used by the profile.
+func (c *Config) resolve(profile string) (map[string]interface{}, []Target, error) {
+	options := c.flagOptions()
+
+	// Use all the targets by default.
+	var names []string
+
+	for name := range c.Targets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	if profile != "" {
+		selectedProfile, exists := c.Profiles[profile]
+
+		if !exists {
+			return nil, nil, fmt.Errorf("the profile \"%s\" doesn't exist", profile)
+		}
+
+		// The settings of the profile override the ones of the file.
+		for key, value := range selectedProfile.flagOptions() {
+			options[key] = value
+		}
+
+		if len(selectedProfile.Targets) > 0 {
+			names = selectedProfile.Targets
+		}
+	}
+
+	var targets []Target
+
+	for _, name := range names {
+		target, exists := c.Targets[name]
+
+		if !exists {
+			return nil, nil, fmt.Errorf("the profile \"%s\" uses the unknown target \"%s\"", profile, name)
+		}
+
+		targets = append(targets, target)
+	}
+
+	return options, targets, nil
+}
+
+// Apply set the flags from the file, the selected profile and the
+// target used for the given engine. The target is the one given by
+// --target, or the only target of the engine.
+func (c *Config) Apply(engine string) {
+	options, targets, err := c.resolve(profileName)
+
+	if err != nil {
+		log.Fatalf("Invalid configuration file \"%s\": %s", configPath, err)
+	}
+
+	for key, value := range options {
+		if err := setFlagOption(flaggy.DefaultParser.Flags, key, value); err != nil {
+			log.Fatalf("Invalid option \"%s\" in the configuration file: %s", key, err)
+		}
+	}
+
+	// Find the target of the engine.
+	var target *Target
+
+	if targetName != "" {
+		selectedTarget, exists := c.Targets[targetName]
+
+		if !exists {
+			log.Fatalf("The target \"%s\" doesn't exist in the configuration file.", targetName)
+		}
+
+		target = &selectedTarget
+	} else if engine != "" {
+		var names []string
+
+		for i := range targets {
+			if targets[i].Engine != engine {
+				continue
+			}
+
+			if target == nil {
+				target = &targets[i]
+			}
+
+			names = append(names, targets[i].Name)
+		}
+
+		// Several targets of the engine, the target must be given.
+		if len(names) > 1 {
+			log.Fatalf("Several targets use %s (%s), select one with --target.", engine, strings.Join(names, ", "))
+		}
+	}
+
+	if target == nil || engine == "" {
+		return
+	}
+
+	if target.Engine != engine {
+		log.Fatalf("The target \"%s\" is for %s, not %s.", target.Name, target.Engine, engine)
+	}
+
+	if err := target.applyOptions(); err != nil {
+		log.Fatalf("Invalid configuration file \"%s\": %s", configPath, err)
+	}
+}
+
+// applyOptions set the flags from the index and the options of the target.
+func (t Target) applyOptions() error {
+	if t.Index != "" {
+		IndexName = t.Index
+	}
+
+	// The options of a target are the engine flags or the global flags.
+	for key, value := range t.Options {
+		flags := engineFlags(t.Engine)
+
+		if findFlag(flags, key) == nil {
+			flags = flaggy.DefaultParser.Flags
+		}
+
+		if err := setFlagOption(flags, key, value); err != nil {
+			return fmt.Errorf("target \"%s\": option \"%s\": %s", t.Name, key, err)
+		}
+	}
+
+	return nil
+}
+
+// SelectedTargets returns the targets used by the profile.
+func (c *Config) SelectedTargets() []Target {
+	_, targets, err := c.resolve(profileName)
+
+	if err != nil {
+		log.Fatalf("Invalid configuration file \"%s\": %s", configPath, err)
+	}
+
+	return targets
+}
+
+// Validate check the options, the targets and the profiles,
+// and returns all the errors found.
+func (c *Config) Validate() []error {
+	var errs []error
+
+	// Check the options of the file and of every profile.
+	checkOptions := func(scope string, options map[string]interface{}) {
+		for key, value := range options {
+			if err := checkFlagOption(flaggy.DefaultParser.Flags, key, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: option \"%s\": %s", scope, key, err))
+			}
+		}
+	}
+
+	checkOptions("options", c.flagOptions())
+
+	for name, profile := range c.Profiles {
+		checkOptions(fmt.Sprintf("profile \"%s\"", name), profile.flagOptions())
+
+		for _, target := range profile.Targets {
+			if _, exists := c.Targets[target]; !exists {
+				errs = append(errs, fmt.Errorf("profile \"%s\": unknown target \"%s\"", name, target))
+			}
+		}
+	}
+
+	for name, target := range c.Targets {
+		if target.Engine != meilisearchName && target.Engine != elasticsearchName {
+			errs = append(errs, fmt.Errorf("target \"%s\": unknown engine \"%s\", use %s or %s", name, target.Engine, meilisearchName, elasticsearchName))
+			continue
+		}
+
+		for key, value := range target.Options {
+			flags := engineFlags(target.Engine)
+
+			if findFlag(flags, key) == nil {
+				flags = flaggy.DefaultParser.Flags
+			}
+
+			if err := checkFlagOption(flags, key, value); err != nil {
+				errs = append(errs, fmt.Errorf("target \"%s\": option \"%s\": %s", name, key, err))
+			}
+		}
+	}
+
+	// Sort the errors to print them in the same order every time.
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+
+	return errs
+}
+
+// ValidateConfig check the configuration file and exit with
+// an error if it is invalid.
+func ValidateConfig() {
+	if loadedConfig == nil {
+		color.Red("There is no configuration file, give it with --config.")
+		os.Exit(1)
+	}
+
+	if errs := loadedConfig.Validate(); len(errs) > 0 {
+		color.Red("The configuration file \"%s\" is invalid:", configPath)
+
+		for _, err := range errs {
+			fmt.Printf("- %s\n", err)
+		}
+
+		os.Exit(1)
+	}
+
+	color.Green("The configuration file \"%s\" is valid, %d targets and %d profiles.", configPath, len(loadedConfig.Targets), len(loadedConfig.Profiles))
+}
+
+// PrintConfig print the configuration as YAML, with the values of
+// the global flags once the file, the environment variables and the
+// flags have been applied.
+func PrintConfig() {
+	printed := struct {
+		Config  string                 `yaml:"config,omitempty"`
+		Profile string                 `yaml:"profile,omitempty"`
+		Options map[string]interface{} `yaml:"options"`
+		Targets map[string]Target      `yaml:"targets,omitempty"`
+	}{
+		Config:  configPath,
+		Profile: profileName,
+		Options: make(map[string]interface{}),
+	}
+
+	for _, flag := range flaggy.DefaultParser.Flags {
+		// The flags selecting the configuration are printed above.
+		if flag.LongName == "config" || flag.LongName == "profile" || flag.LongName == "target" {
+			continue
+		}
+
+		if value, ok := flagValue(flag); ok {
+			printed.Options[flag.LongName] = value
+		}
+	}
+
+	if loadedConfig != nil {
+		printed.Targets = make(map[string]Target)
+
+		for _, target := range loadedConfig.SelectedTargets() {
+			if target.Index == "" {
+				target.Index = IndexName
+			}
+
+			printed.Targets[target.Name] = target
+		}
+	}
+
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(printed); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// applyEnvironment set the flags from the environment variables,
+// named like TATOEBA_INDEX for the global flags and like
+// TATOEBA_MEILISEARCH_HOST for the flags of an engine.
+func applyEnvironment() {
+	applyEnvironmentFlags(environmentPrefix, flaggy.DefaultParser.Flags)
+	applyEnvironmentFlags(environmentPrefix+"MEILISEARCH_", meiliSearchSubcommand.Flags)
+	applyEnvironmentFlags(environmentPrefix+"ELASTICSEARCH_", elasticsearchSubcommand.Flags)
+}
+
+// applyEnvironmentFlags set the given flags from the environment
+// variables with the given prefix.
+func applyEnvironmentFlags(prefix string, flags []*flaggy.Flag) {
+	for _, flag := range flags {
+		if promptFlags[flag.LongName] {
+			continue
+		}
+
+		name := prefix + strings.ToUpper(strings.ReplaceAll(flag.LongName, "-", "_"))
+		value, exists := os.LookupEnv(name)
+
+		if !exists {
+			continue
+		}
+
+		if err := setFlagOption(flags, flag.LongName, value); err != nil {
+			log.Fatalf("Invalid value of the environment variable %s: %s", name, err)
+		}
+	}
+}
+
+// engineFlags returns the flags of the subcommand of an engine.
+func engineFlags(engine string) []*flaggy.Flag {
+	switch engine {
+	case meilisearchName:
+		return meiliSearchSubcommand.Flags
+	case elasticsearchName:
+		return elasticsearchSubcommand.Flags
+	}
+
+	return nil
+}
+
+// findFlag returns the flag with the given long name, or nil.
+func findFlag(flags []*flaggy.Flag, name string) *flaggy.Flag {
+	for _, flag := range flags {
+		if flag.LongName == name {
+			return flag
+		}
+	}
+
+	return nil
+}
+
+// checkFlagOption check the flag exists and the value has the type of the flag.
+func checkFlagOption(flags []*flaggy.Flag, name string, value interface{}) error {
+	// The configuration is selected before the file is read.
+	if name == "config" || name == "profile" || name == "target" {
+		return errors.New("it can't be set in the configuration file")
+	}
+
+	flag := findFlag(flags, name)
+
+	if flag == nil {
+		return errors.New("unknown flag")
+	}
+
+	_, err := convertFlagValue(flag, value)
+
+	return err
+}
+
+// setFlagOption set the variable of a flag from a value of the
+// configuration file or of an environment variable.
+func setFlagOption(flags []*flaggy.Flag, name string, value interface{}) error {
+	if err := checkFlagOption(flags, name, value); err != nil {
+		return err
+	}
+
+	flag := findFlag(flags, name)
+	converted, _ := convertFlagValue(flag, value)
+
+	switch variable := flag.AssignmentVar.(type) {
+	case *string:
+		*variable = converted.(string)
+	case *int:
+		*variable = converted.(int)
+	case *bool:
+		*variable = converted.(bool)
+	case *float64:
+		*variable = converted.(float64)
+	}
+
+	return nil
+}
+
+// saveFlags returns a function restoring the variables of the
+// global flags and of the flags of the engines to their current values.
+func saveFlags() func() {
+	var restores []func()
+
+	for _, flags := range [][]*flaggy.Flag{flaggy.DefaultParser.Flags, meiliSearchSubcommand.Flags, elasticsearchSubcommand.Flags} {
+		for _, flag := range flags {
+			switch variable := flag.AssignmentVar.(type) {
+			case *string:
+				value := *variable
+				restores = append(restores, func() { *variable = value })
+			case *int:
+				value := *variable
+				restores = append(restores, func() { *variable = value })
+			case *bool:
+				value := *variable
+				restores = append(restores, func() { *variable = value })
+			case *float64:
+				value := *variable
+				restores = append(restores, func() { *variable = value })
+			}
+		}
+	}
+
+	return func() {
+		for _, restore := range restores {
+			restore()
+		}
+	}
+}
+
+// convertFlagValue convert a value to the type of the variable of the flag,
+// the strings are parsed like the values given on the command line.
+func convertFlagValue(flag *flaggy.Flag, value interface{}) (interface{}, error) {
+	switch flag.AssignmentVar.(type) {
+	case *string:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case []interface{}:
+			// The lists are joined like the comma separated values.
+			items := make([]string, len(v))
+
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+
+			return strings.Join(items, ","), nil
+		case int, float64, bool:
+			return fmt.Sprint(v), nil
+		}
+	case *int:
+		switch v := value.(type) {
+		case int:
+			return v, nil
+		case float64:
+			if v == math.Trunc(v) {
+				return int(v), nil
+			}
+		case string:
+			if i, err := strconv.Atoi(v); err == nil {
+				return i, nil
+			}
+		}
+
+		return nil, fmt.Errorf("expected an integer, got %v", value)
+	case *bool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+
+		return nil, fmt.Errorf("expected a boolean, got %v", value)
+	case *float64:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f, nil
+			}
+		}
+
+		return nil, fmt.Errorf("expected a number, got %v", value)
+	default:
+		return nil, errors.New("the flag can't be set from the configuration")
+	}
+
+	return nil, fmt.Errorf("expected a string, got %v", value)
+}
+
+// flagValue returns the current value of the variable of a flag.
+func flagValue(flag *flaggy.Flag) (interface{}, bool) {
+	switch variable := flag.AssignmentVar.(type) {
+	case *string:
+		return *variable, true
+	case *int:
+		return *variable, true
+	case *bool:
+		return *variable, true
+	case *float64:
+		return *variable, true
+	}
+
+	return nil, false
+}
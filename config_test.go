@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/integrii/flaggy"
+)
+
+func TestEnvironmentSkipsPromptFlags(t *testing.T) {
+	var host string
+	var isRequired bool
+
+	subcommand := flaggy.NewSubcommand(elasticsearchName)
+	subcommand.String(&host, "", "host", "")
+	subcommand.Bool(&isRequired, "", "password", "")
+
+	// The secret shares its name with the flag asking for it.
+	for name, value := range map[string]string{
+		"TATOEBA_ELASTICSEARCH_HOST":     "http://localhost:9201",
+		"TATOEBA_ELASTICSEARCH_PASSWORD": "secret",
+	} {
+		if err := os.Setenv(name, value); err != nil {
+			t.Fatal(err)
+		}
+
+		name := name
+		t.Cleanup(func() { os.Unsetenv(name) })
+	}
+
+	applyEnvironmentFlags(environmentPrefix+"ELASTICSEARCH_", subcommand.Flags)
+
+	if host != "http://localhost:9201" || isRequired {
+		t.Errorf("host = %s and password prompt = %t, want http://localhost:9201 and false", host, isRequired)
+	}
+}
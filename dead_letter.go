@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	json2 "encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// defaultDeadLetterPath is the dead-letter file used when none is given.
+const defaultDeadLetterPath = "failed_sentences.jsonl"
+
+// FailedSentence describes a sentence rejected by a search engine,
+// written as a line of the dead-letter file.
+type FailedSentence struct {
+	ID       string           `json:"id"`
+	Reason   string           `json:"reason"`
+	Sentence json2.RawMessage `json:"sentence"`
+}
+
+// deadLetterMode define how the failures of a run are
+// combined with the ones of the previous run.
+type deadLetterMode int
+
+const (
+	// The failures replace the ones of the previous run.
+	deadLetterReplace deadLetterMode = iota
+	// The failures are added to the ones of the interrupted run.
+	deadLetterResume
+	// The failures are added to the ones of the previous run.
+	deadLetterAppend
+)
+
+// DeadLetter writes the failed sentences to a JSONL file,
+// it can be used by several goroutines. The failures are written
+// to a temporary file which replaces the dead-letter file once the
+// run is finished, so the failures of the previous run are never
+// lost if the run is interrupted.
+type DeadLetter struct {
+	mutex   sync.Mutex
+	path    string
+	file    *os.File
+	encoder *json2.Encoder
+	count   int
+	mode    deadLetterMode
+	// First failure writing the file, the workers writing
+	// the failed sentences can't return it.
+	err error
+}
+
+// NewDeadLetter returns the dead-letter of a new run, its failures
+// replace the ones of the previous run once it's finished.
+func NewDeadLetter(path string) *DeadLetter {
+	return &DeadLetter{path: path, mode: deadLetterReplace}
+}
+
+// ResumeDeadLetter returns the dead-letter of an interrupted run,
+// the failed sentences are added after the ones already written.
+func ResumeDeadLetter(path string) *DeadLetter {
+	return &DeadLetter{path: path, mode: deadLetterResume}
+}
+
+// AppendDeadLetter returns a dead-letter keeping the failures
+// of the previous run, the failed sentences are added after them.
+func AppendDeadLetter(path string) *DeadLetter {
+	return &DeadLetter{path: path, mode: deadLetterAppend}
+}
+
+// temporaryPath returns the path of the file written during the run.
+func (d *DeadLetter) temporaryPath() string {
+	return d.path + ".tmp"
+}
+
+// open create the temporary file on the first failure.
+func (d *DeadLetter) open() error {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+
+	if d.mode == deadLetterResume {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(d.temporaryPath(), flags, 0644)
+
+	if err != nil {
+		return fmt.Errorf("cannot create the dead-letter file: %s", err)
+	}
+
+	d.file = file
+	d.encoder = json2.NewEncoder(file)
+
+	// Start from the failures of the previous run.
+	if d.mode == deadLetterAppend {
+		previous, err := os.Open(d.path)
+
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("cannot read the dead-letter file: %s", err)
+		}
+
+		defer previous.Close()
+
+		if _, err := io.Copy(file, previous); err != nil {
+			return fmt.Errorf("cannot copy the dead-letter file: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// Write append a failed sentence to the file. The sentences are not
+// written anymore once a write failed, the failure is returned by Close.
+func (d *DeadLetter) Write(ID string, reason string, sentence []byte) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.err != nil {
+		return
+	}
+
+	if d.file == nil {
+		if d.err = d.open(); d.err != nil {
+			return
+		}
+	}
+
+	err := d.encoder.Encode(FailedSentence{
+		ID:       ID,
+		Reason:   reason,
+		Sentence: sentence,
+	})
+
+	if err != nil {
+		d.err = fmt.Errorf("cannot write to the dead-letter file: %s", err)
+		return
+	}
+
+	d.count++
+}
+
+// Count returns the number of failed sentences written during this run.
+func (d *DeadLetter) Count() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.count
+}
+
+// Close the dead-letter file once the run is finished, the
+// temporary file replaces the failures of the previous run.
+// If a failed sentence couldn't be written, the file of the
+// previous run is kept and the failure is returned.
+func (d *DeadLetter) Close() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.err != nil {
+		d.closeFile()
+		return d.err
+	}
+
+	if d.file != nil {
+		err := d.file.Close()
+		d.file = nil
+
+		if err != nil {
+			return fmt.Errorf("cannot close the dead-letter file: %s", err)
+		}
+	} else if d.mode == deadLetterAppend {
+		// Nothing failed, the previous failures are kept.
+		return nil
+	} else if d.mode == deadLetterReplace || !FileExists(d.temporaryPath()) {
+		// Nothing failed, the previous failures have been indexed
+		// again, like the leftover of an interrupted run.
+		if err := removeIfExists(d.temporaryPath()); err != nil {
+			return err
+		}
+
+		return removeIfExists(d.path)
+	}
+
+	if err := os.Rename(d.temporaryPath(), d.path); err != nil {
+		return fmt.Errorf("cannot write the dead-letter file: %s", err)
+	}
+
+	return nil
+}
+
+// Abort close the dead-letter file of a run which failed, the file
+// of the previous run is kept like if the run had been interrupted.
+func (d *DeadLetter) Abort() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.closeFile()
+}
+
+// closeFile close the temporary file without replacing the dead-letter file.
+func (d *DeadLetter) closeFile() {
+	if d.file != nil {
+		_ = d.file.Close()
+		d.file = nil
+	}
+}
+
+// removeIfExists remove the file if it exists.
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// ReadDeadLetter returns the failed sentences of a dead-letter file.
+func ReadDeadLetter(path string) ([]FailedSentence, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	// Read the file line by line, a sentence can be longer
+	// than the default buffer of the scanner.
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var sentences []FailedSentence
+
+	for scanner.Scan() {
+		var sentence FailedSentence
+
+		if err := json2.Unmarshal(scanner.Bytes(), &sentence); err != nil {
+			return nil, fmt.Errorf("cannot decode the dead-letter file: %s", err)
+		}
+
+		sentences = append(sentences, sentence)
+	}
+
+	return sentences, scanner.Err()
+}
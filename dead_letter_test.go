@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// failedIDs returns the IDs of the sentences of the dead-letter file.
+func failedIDs(t *testing.T, path string) []string {
+	t.Helper()
+
+	if !FileExists(path) {
+		return nil
+	}
+
+	sentences, err := ReadDeadLetter(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var IDs []string
+
+	for _, sentence := range sentences {
+		IDs = append(IDs, sentence.ID)
+	}
+
+	return IDs
+}
+
+// writeDeadLetter write the failures of a finished run.
+func writeDeadLetter(t *testing.T, deadLetter *DeadLetter, IDs ...string) {
+	t.Helper()
+
+	for _, ID := range IDs {
+		deadLetter.Write(ID, "rejected", []byte(`{"id":`+ID+`}`))
+	}
+
+	if err := deadLetter.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeadLetterModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		open     func(path string) *DeadLetter
+		failures []string
+		want     []string
+	}{
+		{name: "replace", open: NewDeadLetter, failures: []string{"3"}, want: []string{"3"}},
+		{name: "replace without failure", open: NewDeadLetter, want: nil},
+		{name: "append", open: AppendDeadLetter, failures: []string{"3"}, want: []string{"1", "2", "3"}},
+		{name: "append without failure", open: AppendDeadLetter, want: []string{"1", "2"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "failed_sentences.jsonl")
+			writeDeadLetter(t, NewDeadLetter(path), "1", "2")
+
+			writeDeadLetter(t, test.open(path), test.failures...)
+
+			// The IDs must be the expected ones, in order and once each.
+			if IDs := failedIDs(t, path); !reflect.DeepEqual(IDs, test.want) {
+				t.Errorf("The dead-letter file contains %v, want %v", IDs, test.want)
+			}
+		})
+	}
+}
+
+func TestDeadLetterInterrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed_sentences.jsonl")
+	writeDeadLetter(t, NewDeadLetter(path), "1")
+
+	// The run is interrupted before the file is closed,
+	// the failures of the previous run are kept.
+	interrupted := NewDeadLetter(path)
+	interrupted.Write("2", "rejected", []byte(`{"id":2}`))
+	interrupted.file.Close()
+
+	if IDs := failedIDs(t, path); len(IDs) != 1 || IDs[0] != "1" {
+		t.Fatalf("The dead-letter file contains %v during the run, want [1]", IDs)
+	}
+
+	// The resumed run adds its failures to the ones of the interrupted run.
+	writeDeadLetter(t, ResumeDeadLetter(path), "3")
+
+	if IDs := failedIDs(t, path); len(IDs) != 2 || IDs[0] != "2" || IDs[1] != "3" {
+		t.Errorf("The dead-letter file contains %v, want [2 3]", IDs)
+	}
+
+	if files, _ := ioutil.ReadDir(filepath.Dir(path)); len(files) != 1 {
+		t.Errorf("%d files are left in the directory, want the dead-letter file only", len(files))
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"os"
+)
+
+// SentenceHashes store a hash of every indexed sentence by ID,
+// to find the sentences changed since the last run.
+type SentenceHashes map[string]uint64
+
+// HashSentences returns the hashes of the sentences. The hash covers
+// the JSON document sent to the engines, so the content, the relations,
+// the audio and the transcriptions.
+func HashSentences(sentences map[string]Sentence) SentenceHashes {
+	hashes := make(SentenceHashes, len(sentences))
+
+	var buffer []byte
+
+	for ID, sentence := range sentences {
+		hash := fnv.New64a()
+		buffer = sentence.AppendJSON(buffer[:0])
+		_, _ = hash.Write(buffer)
+
+		hashes[ID] = hash.Sum64()
+	}
+
+	return hashes
+}
+
+// ReadSentenceHashes read the hashes saved by the last run,
+// returns false if there is no previous run.
+func ReadSentenceHashes(path string) (SentenceHashes, bool, error) {
+	file, err := os.Open(path)
+
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer file.Close()
+
+	var hashes SentenceHashes
+
+	if err := gob.NewDecoder(file).Decode(&hashes); err != nil {
+		return nil, false, fmt.Errorf("cannot decode the hashes file \"%s\": %s", path, err)
+	}
+
+	return hashes, true, nil
+}
+
+// Write save the hashes for the next run.
+func (h SentenceHashes) Write(path string) error {
+	// Write to a temporary file first, to keep the previous
+	// hashes if the write fails.
+	file, err := os.Create(path + ".tmp")
+
+	if err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(file).Encode(h); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot encode the hashes: %s", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// Restore set back the previous hashes of the given sentences, or remove
+// their hash if they were not indexed before. The sentences the engine
+// didn't index or delete are then sent again by the next run.
+func (h SentenceHashes) Restore(previous SentenceHashes, IDs []string) {
+	for _, ID := range IDs {
+		if hash, exists := previous[ID]; exists {
+			h[ID] = hash
+		} else {
+			delete(h, ID)
+		}
+	}
+}
+
+// Delta returns the sentences added or changed since the previous hashes
+// and the IDs of the sentences which have been deleted.
+func Delta(previous, current SentenceHashes, sentences map[string]Sentence) (map[string]Sentence, []string) {
+	changed := make(map[string]Sentence)
+
+	for ID, hash := range current {
+		if previousHash, exists := previous[ID]; !exists || previousHash != hash {
+			changed[ID] = sentences[ID]
+		}
+	}
+
+	var deleted []string
+
+	for ID := range previous {
+		if _, exists := current[ID]; !exists {
+			deleted = append(deleted, ID)
+		}
+	}
+
+	return changed, deleted
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestDeltaRestoreFailed(t *testing.T) {
+	sentences := fixtureSentences(t)
+
+	// The sentence 2 is new, the sentence 1 changed and the sentence 6 has been removed.
+	previous := HashSentences(sentences)
+	delete(previous, "2")
+
+	changedSentence := sentences["1"]
+	changedSentence.Content = "Let's try something else."
+	sentences["1"] = changedSentence
+	delete(sentences, "6")
+
+	current := HashSentences(sentences)
+	changed, deleted := Delta(previous, current, sentences)
+
+	if len(changed) != 2 || len(deleted) != 1 || deleted[0] != "6" {
+		t.Fatalf("Delta() = %d changed and %v deleted, want 2 and [6]", len(changed), deleted)
+	}
+
+	// The engine rejected all the changes, the hashes
+	// saved are the ones of the previous run.
+	current.Restore(previous, []string{"1", "2", "6"})
+
+	if len(current) != len(previous) {
+		t.Fatalf("%d hashes have been restored, want %d", len(current), len(previous))
+	}
+
+	for ID, hash := range previous {
+		if current[ID] != hash {
+			t.Errorf("The hash of the sentence %s has not been restored", ID)
+		}
+	}
+
+	// The next run sends the rejected changes again.
+	changed, deleted = Delta(current, HashSentences(sentences), sentences)
+
+	if _, exists := changed["1"]; !exists || len(changed) != 2 || len(deleted) != 1 {
+		t.Errorf("The next run sends %d sentences and deletes %v, want 2 and [6]", len(changed), deleted)
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	json2 "encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/fatih/color"
+)
+
+// versionedIndexFormat is the timestamp format appended to the
+// index name for every build.
+const versionedIndexFormat = "20060102150405"
+
+// newVersionedIndex returns the name of a new index to build into,
+// named after the alias.
+func newVersionedIndex(alias string) string {
+	return alias + "-" + time.Now().UTC().Format(versionedIndexFormat)
+}
+
+// decodeResponse check the Elasticsearch response and decode its
+// body into v when v is not nil.
+func decodeResponse(res *esapi.Response, err error, action string, v interface{}) error {
+	if err != nil {
+		return fmt.Errorf("cannot %s: %s", action, err)
+	}
+
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("cannot %s: %s", action, res)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	if err := json2.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("cannot %s: %s", action, err)
+	}
+
+	return nil
+}
+
+// versionedIndexes returns the indexes built by previous runs, sorted
+// from the oldest to the newest, the complete ones and the ones of
+// the builds which failed or are still running.
+func (e Elasticsearch) versionedIndexes() (complete, incomplete []string, err error) {
+	// Get the indexes matching the index name.
+	var indexes map[string]struct {
+		Mappings struct {
+			Meta map[string]interface{} `json:"_meta"`
+		} `json:"mappings"`
+	}
+
+	res, err := e.client.Indices.Get([]string{e.indexName + "-*"}, e.client.Indices.Get.WithAllowNoIndices(true))
+
+	if err := decodeResponse(res, err, "list indexes", &indexes); err != nil {
+		return nil, nil, err
+	}
+
+	// Keep only the indexes created by this tool.
+	pattern := regexp.MustCompile("^" + regexp.QuoteMeta(e.indexName) + `-\d{14}$`)
+
+	for name, index := range indexes {
+		if !pattern.MatchString(name) {
+			continue
+		}
+
+		// The indexes built by the older versions have no marker,
+		// they are complete as the alias has been moved to them.
+		if finished, marked := index.Mappings.Meta["complete"]; !marked || finished == true {
+			complete = append(complete, name)
+		} else {
+			incomplete = append(incomplete, name)
+		}
+	}
+
+	// The timestamp format keeps the lexical and chronological order the same.
+	sort.Strings(complete)
+	sort.Strings(incomplete)
+
+	return complete, incomplete, nil
+}
+
+// markComplete mark the build of the index as complete,
+// it can then be used by a rollback.
+func (e Elasticsearch) markComplete(index string) error {
+	body := strings.NewReader(`{"_meta": {"complete": true}}`)
+
+	res, err := e.client.Indices.PutMapping(body, e.client.Indices.PutMapping.WithIndex(index))
+
+	return decodeResponse(res, err, "mark the index as complete", nil)
+}
+
+// aliasedIndexes returns the indexes the alias currently points to
+// and if a concrete index has been created with the alias name.
+func (e Elasticsearch) aliasedIndexes() (indexes []string, concrete bool, err error) {
+	var response map[string]interface{}
+
+	res, err := e.client.Indices.Get([]string{e.indexName}, e.client.Indices.Get.WithIgnoreUnavailable(true))
+
+	if err := decodeResponse(res, err, "get the alias", &response); err != nil {
+		return nil, false, err
+	}
+
+	for name := range response {
+		if name == e.indexName {
+			concrete = true
+		} else {
+			indexes = append(indexes, name)
+		}
+	}
+
+	return indexes, concrete, nil
+}
+
+// moveAlias atomically points the alias to the given index.
+func (e Elasticsearch) moveAlias(index string) error {
+	current, concrete, err := e.aliasedIndexes()
+
+	if err != nil {
+		return err
+	}
+
+	var actions []map[string]interface{}
+
+	// An index created by an older version of this tool has the alias
+	// name, remove it in the same request to avoid any downtime.
+	if concrete {
+		actions = append(actions, map[string]interface{}{
+			"remove_index": map[string]string{"index": e.indexName},
+		})
+	}
+
+	for _, name := range current {
+		actions = append(actions, map[string]interface{}{
+			"remove": map[string]string{"index": name, "alias": e.indexName},
+		})
+	}
+
+	actions = append(actions, map[string]interface{}{
+		"add": map[string]string{"index": index, "alias": e.indexName},
+	})
+
+	body, err := json2.Marshal(map[string]interface{}{"actions": actions})
+
+	if err != nil {
+		return fmt.Errorf("cannot encode the alias actions: %s", err)
+	}
+
+	res, err := e.client.Indices.UpdateAliases(bytes.NewReader(body))
+
+	if err := decodeResponse(res, err, "move the alias", nil); err != nil {
+		return err
+	}
+
+	printColor(e.output, color.FgGreen, "The alias \"%s\" now points to \"%s\".", e.indexName, index)
+
+	return nil
+}
+
+// deleteOldIndexes delete the old indexes, keeping the last complete ones
+// for a rollback. The incomplete indexes older than the one used by the
+// alias are left by failed builds, they are deleted too.
+func (e Elasticsearch) deleteOldIndexes() error {
+	current, _, err := e.aliasedIndexes()
+
+	if err != nil {
+		return err
+	}
+
+	// Never delete an index used by the alias.
+	inUse := make(map[string]bool)
+
+	for _, name := range current {
+		inUse[name] = true
+	}
+
+	complete, incomplete, err := e.versionedIndexes()
+
+	if err != nil {
+		return err
+	}
+
+	var old []string
+
+	for _, name := range complete {
+		if !inUse[name] {
+			old = append(old, name)
+		}
+	}
+
+	var toDelete []string
+
+	if len(old) > e.keepIndexes {
+		toDelete = old[:len(old)-e.keepIndexes]
+	}
+
+	for _, name := range incomplete {
+		if len(current) > 0 && name < current[0] {
+			toDelete = append(toDelete, name)
+		}
+	}
+
+	if len(toDelete) == 0 {
+		return nil
+	}
+
+	res, err := e.client.Indices.Delete(toDelete)
+
+	if err := decodeResponse(res, err, "delete old indexes", nil); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.output, "Deleted %d old indexes.\n", len(toDelete))
+
+	return nil
+}
+
+// countDocuments returns the number of documents in the given index.
+func (e Elasticsearch) countDocuments(index string) (int, error) {
+	res, err := e.client.Indices.Refresh(e.client.Indices.Refresh.WithIndex(index))
+
+	if err := decodeResponse(res, err, "refresh the index", nil); err != nil {
+		return 0, err
+	}
+
+	var count struct {
+		Count int `json:"count"`
+	}
+
+	res, err = e.client.Count(e.client.Count.WithIndex(index))
+
+	if err := decodeResponse(res, err, "count documents", &count); err != nil {
+		return 0, err
+	}
+
+	return count.Count, nil
+}
+
+// Rollback points the alias back to the index built before
+// the current one.
+func (e Elasticsearch) Rollback() error {
+	current, _, err := e.aliasedIndexes()
+
+	if err != nil {
+		return err
+	}
+
+	indexes, _, err := e.versionedIndexes()
+
+	if err != nil {
+		return err
+	}
+
+	// Find the newest complete index older than the one used by the alias.
+	var previous string
+
+	for _, name := range indexes {
+		if len(current) > 0 && name >= current[0] {
+			break
+		}
+
+		previous = name
+	}
+
+	if previous == "" {
+		printColor(e.output, color.FgRed, "There is no previous index to rollback to.")
+		return nil
+	}
+
+	return e.moveAlias(previous)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	json2 "encoding/json"
+	"fmt"
+)
+
+// Declare the Elasticsearch analysis plugins the mapping can use.
+const (
+	pluginICU      = "analysis-icu"
+	pluginKuromoji = "analysis-kuromoji"
+	pluginSmartCN  = "analysis-smartcn"
+)
+
+// elasticsearchDateFormat is the format of the dates exported by Tatoeba.
+const elasticsearchDateFormat = "yyyy-MM-dd HH:mm:ss||strict_date_optional_time"
+
+// builtinLanguageAnalyzers map Tatoeba's language codes to the
+// language analyzers shipped with Elasticsearch.
+var builtinLanguageAnalyzers = map[string]string{
+	"eng": "english",
+	"fra": "french",
+	"deu": "german",
+	"spa": "spanish",
+	"ita": "italian",
+	"por": "portuguese",
+	"rus": "russian",
+	"nld": "dutch",
+}
+
+// languageAnalyzers returns the analyzer to use for the `content_<language>`
+// field of every language, depending on the installed plugins.
+func languageAnalyzers(plugins map[string]bool) map[string]string {
+	analyzers := make(map[string]string)
+
+	for language, analyzer := range builtinLanguageAnalyzers {
+		analyzers[language] = analyzer
+	}
+
+	// Use the ICU analyzer for the CJK languages when the dedicated
+	// plugins are not installed, or the builtin CJK one as last resort.
+	fallback := "cjk"
+
+	if plugins[pluginICU] {
+		fallback = "icu_analyzer"
+	}
+
+	analyzers["jpn"] = fallback
+	analyzers["cmn"] = fallback
+
+	if plugins[pluginKuromoji] {
+		analyzers["jpn"] = "kuromoji"
+	}
+
+	if plugins[pluginSmartCN] {
+		analyzers["cmn"] = "smartcn"
+	}
+
+	return analyzers
+}
+
+// analyzedLanguages are the languages having a field of their own,
+// whatever the plugins installed.
+var analyzedLanguages = languageAnalyzers(nil)
+
+// contentField returns the field analyzing the content of the sentences
+// of the language, or an empty name if the language has no analyzer.
+func contentField(language string) string {
+	if _, exists := analyzedLanguages[language]; !exists {
+		return ""
+	}
+
+	return "content_" + language
+}
+
+// elasticsearchDocument returns the document of the sentence, its content
+// is copied to the field of its language only, to be analyzed once.
+func elasticsearchDocument(sentence Sentence) ([]byte, error) {
+	document, err := json2.Marshal(sentence)
+	field := contentField(sentence.Language)
+
+	if err != nil || field == "" {
+		return document, err
+	}
+
+	content, err := json2.Marshal(sentence.Content)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Add the field before the closing brace of the object.
+	document = append(document[:len(document)-1], `,"`+field+`":`...)
+	document = append(document, content...)
+
+	return append(document, '}'), nil
+}
+
+// elasticsearchSuggestAnalysis returns the analyzers used by the
+// prefix sub-field of the CJK sentences.
+func elasticsearchSuggestAnalysis() map[string]interface{} {
+	return map[string]interface{}{
+		"filter": map[string]interface{}{
+			"prefix_edge_ngram": map[string]interface{}{
+				"type":     "edge_ngram",
+				"min_gram": 1,
+				"max_gram": 20,
+			},
+		},
+		"analyzer": map[string]interface{}{
+			// CJK languages don't separate words with spaces,
+			// index the prefixes of the whole sentence instead.
+			"cjk_prefix": map[string]interface{}{
+				"type":      "custom",
+				"tokenizer": "keyword",
+				"filter":    []string{"cjk_width", "lowercase", "prefix_edge_ngram"},
+			},
+			"cjk_prefix_search": map[string]interface{}{
+				"type":      "custom",
+				"tokenizer": "keyword",
+				"filter":    []string{"cjk_width", "lowercase"},
+			},
+		},
+	}
+}
+
+// elasticsearchSuggestFields returns the sub-fields of `content` used
+// for the suggestions as you type.
+func elasticsearchSuggestFields() map[string]interface{} {
+	return map[string]interface{}{
+		// Completion suggester, the suggestions are filtered by language.
+		"suggest": map[string]interface{}{
+			"type": "completion",
+			"contexts": []map[string]interface{}{
+				{
+					"name": "language",
+					"type": "category",
+					"path": "language",
+				},
+			},
+		},
+		// Prefix queries on the CJK sentences.
+		"cjk_prefix": map[string]interface{}{
+			"type":            "text",
+			"analyzer":        "cjk_prefix",
+			"search_analyzer": "cjk_prefix_search",
+		},
+	}
+}
+
+// elasticsearchMapping returns the body used to create the index.
+func elasticsearchMapping(plugins map[string]bool, suggest bool) map[string]interface{} {
+	// The main `content` field is analyzed with ICU when available
+	// to get a decent tokenization of every language.
+	contentAnalyzer := "standard"
+
+	if plugins[pluginICU] {
+		contentAnalyzer = "icu_analyzer"
+	}
+
+	content := map[string]interface{}{
+		"type":     "text",
+		"analyzer": contentAnalyzer,
+	}
+
+	// Add the suggestion sub-fields if asked.
+	if suggest {
+		content["fields"] = elasticsearchSuggestFields()
+	}
+
+	properties := map[string]interface{}{
+		"id":                    map[string]interface{}{"type": "integer"},
+		"language":              map[string]interface{}{"type": "keyword"},
+		"content":               content,
+		"username":              map[string]interface{}{"type": "keyword"},
+		"added_at":              map[string]interface{}{"type": "date", "format": elasticsearchDateFormat},
+		"updated_at":            map[string]interface{}{"type": "date", "format": elasticsearchDateFormat},
+		"direct_translations":   map[string]interface{}{"type": "integer"},
+		"indirect_translations": map[string]interface{}{"type": "integer"},
+		"translated_languages":  map[string]interface{}{"type": "keyword"},
+		"audio_username":        map[string]interface{}{"type": "keyword"},
+		// Transcriptions are nested, so the script and the text
+		// of a query match the same transcription.
+		"transcriptions": map[string]interface{}{
+			"type": "nested",
+			"properties": map[string]interface{}{
+				"script_name":   map[string]interface{}{"type": "keyword"},
+				"username":      map[string]interface{}{"type": "keyword"},
+				"transcription": map[string]interface{}{"type": "text", "analyzer": contentAnalyzer},
+			},
+		},
+	}
+
+	// Create a field for every language analyzer, only the
+	// sentences of the language have a content in it.
+	for language, analyzer := range languageAnalyzers(plugins) {
+		properties[contentField(language)] = map[string]interface{}{
+			"type":     "text",
+			"analyzer": analyzer,
+		}
+	}
+
+	return map[string]interface{}{
+		"mappings": map[string]interface{}{
+			// The build is marked complete once all the sentences are indexed.
+			"_meta":   map[string]interface{}{"complete": false},
+			"dynamic": "strict",
+			// The language fields are a copy of the content,
+			// the source stays the one of the sentence.
+			"_source":    map[string]interface{}{"excludes": []string{"content_*"}},
+			"properties": properties,
+		},
+	}
+}
+
+// installedPlugins returns the analysis plugins installed on the cluster.
+func (e Elasticsearch) installedPlugins() (map[string]bool, error) {
+	// Ask the cluster for the list of its plugins.
+	res, err := e.client.Cat.Plugins(e.client.Cat.Plugins.WithFormat("json"))
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot list plugins: %s", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("cannot list plugins: %s", res)
+	}
+
+	// Decode the response.
+	var rows []struct {
+		Component string `json:"component"`
+	}
+
+	if err := json2.NewDecoder(res.Body).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("cannot decode the plugins list: %s", err)
+	}
+
+	// A plugin is installed on every node, keep the components only.
+	plugins := make(map[string]bool)
+
+	for _, row := range rows {
+		plugins[row.Component] = true
+	}
+
+	return plugins, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v7"
+)
+
+// Declare the environment variables holding the Elasticsearch secrets,
+// so they never have to be written on the command line.
+const (
+	envElasticsearchUsername     = "ELASTICSEARCH_USERNAME"
+	envElasticsearchPassword     = "ELASTICSEARCH_PASSWORD"
+	envElasticsearchAPIKey       = "ELASTICSEARCH_API_KEY"
+	envElasticsearchServiceToken = "ELASTICSEARCH_SERVICE_TOKEN"
+	envElasticsearchCloudID      = "ELASTICSEARCH_CLOUD_ID"
+)
+
+// addresses returns the formatted list of hosts, separated by commas.
+func (e Elasticsearch) addresses() []string {
+	var addresses []string
+
+	for _, host := range strings.Split(e.host, ",") {
+		host = strings.TrimSpace(host)
+
+		if host == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
+			host = "http://" + host
+		}
+
+		addresses = append(addresses, host)
+	}
+
+	return addresses
+}
+
+// configureSecurity set the credentials, the Cloud ID and the TLS
+// options of the Elasticsearch client configuration.
+func (e Elasticsearch) configureSecurity(config *elasticsearch.Config) error {
+	// The Cloud ID replaces the addresses.
+	cloudID := e.cloudID
+
+	if cloudID == "" {
+		cloudID = os.Getenv(envElasticsearchCloudID)
+	}
+
+	if cloudID != "" {
+		config.CloudID = cloudID
+		config.Addresses = nil
+	}
+
+	// Use the username and password for the basic authentication.
+	config.Username = e.username
+
+	if config.Username == "" {
+		config.Username = os.Getenv(envElasticsearchUsername)
+	}
+
+	config.Password = os.Getenv(envElasticsearchPassword)
+
+	// The terminal is only a fallback, a job without terminal
+	// keeps working with the environment variable.
+	if config.Password == "" && e.passwordRequired && isTerminal() {
+		config.Password = askSecret("Please enter the Elasticsearch password: ")
+	}
+
+	// The API key overrides the username and password.
+	config.APIKey = os.Getenv(envElasticsearchAPIKey)
+
+	// The service token overrides the username and password too.
+	config.ServiceToken = os.Getenv(envElasticsearchServiceToken)
+
+	// Elasticsearch 8 generates its own certificate on the first launch,
+	// trust it with its fingerprint.
+	config.CertificateFingerprint = e.caFingerprint
+
+	// Keep the default transport if no TLS option has been given. The client
+	// checks the fingerprint by changing the dialer of the transport, it must
+	// have its own one to not change the transport of the whole process.
+	if e.caCert == "" && e.clientCert == "" && !e.insecureSkipVerify && e.caFingerprint == "" {
+		return nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: e.insecureSkipVerify,
+	}
+
+	// Trust the given certificate authority.
+	if e.caCert != "" {
+		cert, err := ioutil.ReadFile(e.caCert)
+
+		if err != nil {
+			return fmt.Errorf("cannot read the CA certificate: %s", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(cert) {
+			return fmt.Errorf("cannot parse the CA certificate \"%s\"", e.caCert)
+		}
+	}
+
+	// Authenticate with a client certificate.
+	if e.clientCert != "" {
+		cert, err := tls.LoadX509KeyPair(e.clientCert, e.clientKey)
+
+		if err != nil {
+			return fmt.Errorf("cannot load the client certificate: %s", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	config.Transport = transport
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	json2 "encoding/json"
+	"fmt"
+)
+
+// bulkLoadSettings returns the settings used to create the index.
+// The replicas and the refresh are disabled during the bulk load
+// to speed it up, they are restored by `restoreSettings`.
+func (e Elasticsearch) bulkLoadSettings() map[string]interface{} {
+	return map[string]interface{}{
+		"index": map[string]interface{}{
+			"number_of_shards":   e.shards,
+			"number_of_replicas": 0,
+			"refresh_interval":   "-1",
+			"codec":              e.codec,
+		},
+	}
+}
+
+// restoreSettings set the configured replicas and refresh interval
+// on the index once the bulk load is done.
+func (e Elasticsearch) restoreSettings() error {
+	body, err := json2.Marshal(map[string]interface{}{
+		"index": map[string]interface{}{
+			"number_of_replicas": e.replicas,
+			"refresh_interval":   e.refreshInterval,
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("cannot encode the settings: %s", err)
+	}
+
+	res, err := e.client.Indices.PutSettings(bytes.NewReader(body), e.client.Indices.PutSettings.WithIndex(e.index))
+
+	return decodeResponse(res, err, "restore the index settings", nil)
+}
+
+// forceMerge merge the segments of the index into a single one.
+func (e Elasticsearch) forceMerge() error {
+	fmt.Fprint(e.output, "Force merging the index...")
+
+	res, err := e.client.Indices.Forcemerge(
+		e.client.Indices.Forcemerge.WithIndex(e.index),
+		e.client.Indices.Forcemerge.WithMaxNumSegments(1),
+	)
+
+	if err := decodeResponse(res, err, "force merge the index", nil); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.output, "%c[2K\rThe index has been force merged\n", 27)
+
+	return nil
+}
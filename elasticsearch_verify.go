@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	json2 "encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+)
+
+// CountDocuments returns the number of documents in the index used by the alias.
+func (e Elasticsearch) CountDocuments() (int, error) {
+	return e.countDocuments(e.indexName)
+}
+
+// DocumentIDs returns the IDs of all the documents in the index
+// used by the alias, paging over them sorted by ID.
+func (e Elasticsearch) DocumentIDs() (map[string]bool, error) {
+	IDs := make(map[string]bool)
+
+	// The ID of the last document of the previous page.
+	var searchAfter []interface{}
+
+	for {
+		query := map[string]interface{}{
+			"size":             10000,
+			"_source":          false,
+			"track_total_hits": false,
+			"sort":             []map[string]string{{"id": "asc"}},
+		}
+
+		if searchAfter != nil {
+			query["search_after"] = searchAfter
+		}
+
+		body, err := json2.Marshal(query)
+
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode the query: %s", err)
+		}
+
+		var response struct {
+			Hits struct {
+				Hits []struct {
+					ID   string        `json:"_id"`
+					Sort []interface{} `json:"sort"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+
+		res, err := e.client.Search(
+			e.client.Search.WithIndex(e.indexName),
+			e.client.Search.WithBody(bytes.NewReader(body)),
+		)
+
+		if err := decodeResponse(res, err, "list the documents", &response); err != nil {
+			return nil, err
+		}
+
+		hits := response.Hits.Hits
+
+		if len(hits) == 0 {
+			return IDs, nil
+		}
+
+		for _, hit := range hits {
+			IDs[hit.ID] = true
+		}
+
+		searchAfter = hits[len(hits)-1].Sort
+	}
+}
+
+// FailureReasons returns the reasons of the sentences
+// of the dead-letter file, once the run is finished.
+func (e Elasticsearch) FailureReasons() (map[string]string, error) {
+	reasons := make(map[string]string)
+
+	if e.deadLetter == nil || !FileExists(e.deadLetterPath) {
+		return reasons, nil
+	}
+
+	sentences, err := ReadDeadLetter(e.deadLetterPath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sentence := range sentences {
+		reasons[sentence.ID] = "rejected by the engine: " + sentence.Reason
+	}
+
+	return reasons, nil
+}
+
+// Push index the given sentences in the index used by the alias,
+// it returns the IDs of the sentences Elasticsearch rejected.
+func (e *Elasticsearch) Push(sentences map[string]Sentence) ([]string, error) {
+	// Keep the failures of the previous runs, they are not pushed again.
+	if err := e.newBulkIndexer(e.indexName, AppendDeadLetter(e.deadLetterPath)); err != nil {
+		return nil, err
+	}
+
+	// The failures are reported by the workers.
+	var mutex sync.Mutex
+	var failed []string
+
+	for ID, sentence := range sentences {
+		ID := ID
+
+		err := e.add(ID, sentence, func(rejected bool) {
+			if rejected {
+				mutex.Lock()
+				failed = append(failed, ID)
+				mutex.Unlock()
+			}
+		})
+
+		if err != nil {
+			e.abort()
+			return nil, err
+		}
+	}
+
+	if _, err := e.close(); err != nil {
+		return nil, err
+	}
+
+	return failed, nil
+}
+
+// Delete remove the given sentences from the index used by the alias,
+// it returns the IDs of the sentences Elasticsearch didn't delete.
+func (e *Elasticsearch) Delete(IDs []string) ([]string, error) {
+	if err := e.newBulkIndexer(e.indexName, nil); err != nil {
+		return nil, err
+	}
+
+	// The failures are reported by the workers.
+	var mutex sync.Mutex
+	var failed []string
+
+	for _, ID := range IDs {
+		err := e.bulkIndexer.Add(context.Background(), esutil.BulkIndexerItem{
+			Action:     "delete",
+			DocumentID: ID,
+			OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+				// The sentence has already been deleted.
+				if err == nil && res.Status == 404 {
+					return
+				}
+
+				if err != nil {
+					fmt.Fprintf(e.output, "Cannot delete sentence %s: %s\n", item.DocumentID, err)
+				} else {
+					fmt.Fprintf(e.output, "Cannot delete sentence %s: %s: %s\n", item.DocumentID, res.Error.Type, res.Error.Reason)
+				}
+
+				mutex.Lock()
+				failed = append(failed, item.DocumentID)
+				mutex.Unlock()
+			},
+		})
+
+		if err != nil {
+			e.abort()
+			return nil, fmt.Errorf("cannot delete the sentences: %s", err)
+		}
+	}
+
+	if err := e.bulkIndexer.Close(context.Background()); err != nil {
+		return nil, fmt.Errorf("cannot delete the sentences: %s", err)
+	}
+
+	return failed, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// serverVersion returns the major version of the Elasticsearch server
+// and its full version number.
+func (e Elasticsearch) serverVersion() (int, string, error) {
+	var info struct {
+		Version struct {
+			Number string `json:"number"`
+		} `json:"version"`
+	}
+
+	res, err := e.client.Info()
+
+	if err := decodeResponse(res, err, "get the server version", &info); err != nil {
+		return 0, "", err
+	}
+
+	// Keep the major version only, like 8 for 8.10.2.
+	major, err := strconv.Atoi(strings.SplitN(info.Version.Number, ".", 2)[0])
+
+	if err != nil {
+		return 0, "", fmt.Errorf("unexpected Elasticsearch version \"%s\"", info.Version.Number)
+	}
+
+	return major, info.Version.Number, nil
+}
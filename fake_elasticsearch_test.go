@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	json2 "encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeElasticsearch is an in-process Elasticsearch keeping the indexes
+// and the aliases in memory, it implements the routes used by the indexer.
+type fakeElasticsearch struct {
+	mutex   sync.Mutex
+	server  *httptest.Server
+	indexes map[string]*fakeElasticsearchIndex
+	// Index pointed by each alias.
+	aliases map[string]string
+	// Reason of the rejection of the documents by ID.
+	reject map[string]string
+}
+
+// fakeElasticsearchIndex describes an index of the fake Elasticsearch.
+type fakeElasticsearchIndex struct {
+	// Body of the creation of the index, with its mapping and settings.
+	created   map[string]interface{}
+	meta      map[string]interface{}
+	settings  []map[string]interface{}
+	documents map[string]json2.RawMessage
+}
+
+// addIndex add an index built by a previous run, marked complete or not,
+// without the marker when complete is nil.
+func (f *fakeElasticsearch) addIndex(name string, complete interface{}) {
+	index := &fakeElasticsearchIndex{documents: make(map[string]json2.RawMessage)}
+
+	if complete != nil {
+		index.meta = map[string]interface{}{"complete": complete}
+	}
+
+	f.indexes[name] = index
+}
+
+// newFakeElasticsearch start a fake Elasticsearch stopped at the end of the test.
+func newFakeElasticsearch(t *testing.T) *fakeElasticsearch {
+	fake := &fakeElasticsearch{
+		indexes: make(map[string]*fakeElasticsearchIndex),
+		aliases: make(map[string]string),
+		reject:  make(map[string]string),
+	}
+
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(fake.server.Close)
+
+	return fake
+}
+
+// serveHTTP route the requests like Elasticsearch does.
+func (f *fakeElasticsearch) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// The client refuses to talk to a server without this header.
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case r.URL.Path == "/":
+		f.write(w, http.StatusOK, map[string]interface{}{
+			"version": map[string]string{"number": "7.17.10", "build_flavor": "default"},
+			"tagline": "You Know, for Search",
+		})
+	case r.URL.Path == "/_cat/plugins":
+		f.write(w, http.StatusOK, []interface{}{})
+	case r.URL.Path == "/_aliases":
+		f.updateAliases(w, r)
+	case r.URL.Path == "/_bulk":
+		f.bulk(w, r, "")
+	case len(segments) == 2 && segments[1] == "_bulk":
+		f.bulk(w, r, segments[0])
+	case len(segments) == 2 && segments[1] == "_mapping":
+		f.putMapping(w, r, segments[0])
+	case len(segments) == 2 && segments[1] == "_settings":
+		f.putSettings(w, r, segments[0])
+	case len(segments) == 2 && (segments[1] == "_refresh" || segments[1] == "_forcemerge"):
+		f.write(w, http.StatusOK, map[string]interface{}{})
+	case len(segments) == 2 && segments[1] == "_count":
+		f.count(w, segments[0])
+	case len(segments) == 1:
+		f.indexRequest(w, r, segments[0])
+	default:
+		f.write(w, http.StatusNotFound, map[string]string{"error": "unknown route " + r.URL.Path})
+	}
+}
+
+// write send the value as the JSON response.
+func (f *fakeElasticsearch) write(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json2.NewEncoder(w).Encode(value)
+}
+
+// resolve returns the indexes matching the comma separated names,
+// the names can be wildcards or aliases.
+func (f *fakeElasticsearch) resolve(names string) []string {
+	var indexes []string
+
+	for _, name := range strings.Split(names, ",") {
+		if index, exists := f.aliases[name]; exists {
+			indexes = append(indexes, index)
+			continue
+		}
+
+		for index := range f.indexes {
+			if matched, _ := path.Match(name, index); matched {
+				indexes = append(indexes, index)
+			}
+		}
+	}
+
+	sort.Strings(indexes)
+
+	return indexes
+}
+
+// indexRequest create, get, check or delete indexes.
+func (f *fakeElasticsearch) indexRequest(w http.ResponseWriter, r *http.Request, names string) {
+	switch r.Method {
+	case http.MethodPut:
+		if _, exists := f.indexes[names]; exists {
+			f.write(w, http.StatusBadRequest, map[string]interface{}{
+				"error": map[string]string{"type": "resource_already_exists_exception"},
+			})
+			return
+		}
+
+		index := &fakeElasticsearchIndex{documents: make(map[string]json2.RawMessage)}
+
+		if err := json2.NewDecoder(r.Body).Decode(&index.created); err != nil {
+			f.write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		if mappings, ok := index.created["mappings"].(map[string]interface{}); ok {
+			index.meta, _ = mappings["_meta"].(map[string]interface{})
+		}
+
+		f.indexes[names] = index
+		f.write(w, http.StatusOK, map[string]interface{}{"acknowledged": true, "index": names})
+	case http.MethodHead, http.MethodGet:
+		response := make(map[string]interface{})
+
+		for _, index := range f.resolve(names) {
+			response[index] = map[string]interface{}{
+				"mappings": map[string]interface{}{"_meta": f.indexes[index].meta},
+			}
+		}
+
+		if len(response) == 0 && !strings.Contains(names, "*") && r.URL.Query().Get("ignore_unavailable") != "true" {
+			f.write(w, http.StatusNotFound, map[string]interface{}{
+				"error": map[string]string{"type": "index_not_found_exception"},
+			})
+			return
+		}
+
+		f.write(w, http.StatusOK, response)
+	case http.MethodDelete:
+		for _, index := range f.resolve(names) {
+			delete(f.indexes, index)
+		}
+
+		f.write(w, http.StatusOK, map[string]interface{}{"acknowledged": true})
+	default:
+		f.write(w, http.StatusMethodNotAllowed, map[string]string{"error": r.Method})
+	}
+}
+
+// putMapping replace the meta field of the mapping of an index.
+func (f *fakeElasticsearch) putMapping(w http.ResponseWriter, r *http.Request, name string) {
+	var mapping struct {
+		Meta map[string]interface{} `json:"_meta"`
+	}
+
+	if err := json2.NewDecoder(r.Body).Decode(&mapping); err != nil {
+		f.write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	for _, index := range f.resolve(name) {
+		f.indexes[index].meta = mapping.Meta
+	}
+
+	f.write(w, http.StatusOK, map[string]interface{}{"acknowledged": true})
+}
+
+// putSettings keep the settings updated on an index.
+func (f *fakeElasticsearch) putSettings(w http.ResponseWriter, r *http.Request, name string) {
+	var settings map[string]interface{}
+
+	if err := json2.NewDecoder(r.Body).Decode(&settings); err != nil {
+		f.write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	for _, index := range f.resolve(name) {
+		f.indexes[index].settings = append(f.indexes[index].settings, settings)
+	}
+
+	f.write(w, http.StatusOK, map[string]interface{}{"acknowledged": true})
+}
+
+// count returns the number of documents of the indexes.
+func (f *fakeElasticsearch) count(w http.ResponseWriter, names string) {
+	count := 0
+
+	for _, index := range f.resolve(names) {
+		count += len(f.indexes[index].documents)
+	}
+
+	f.write(w, http.StatusOK, map[string]int{"count": count})
+}
+
+// bulk index or delete the documents of the NDJSON body,
+// the documents to reject are answered with an error.
+func (f *fakeElasticsearch) bulk(w http.ResponseWriter, r *http.Request, defaultIndex string) {
+	var items []map[string]interface{}
+
+	errors := false
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var action map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+
+		if err := json2.Unmarshal(scanner.Bytes(), &action); err != nil || len(action) != 1 {
+			f.write(w, http.StatusBadRequest, map[string]string{"error": "invalid action " + scanner.Text()})
+			return
+		}
+
+		for name, meta := range action {
+			if meta.Index == "" {
+				meta.Index = defaultIndex
+			}
+
+			// The document follows the index action.
+			var document json2.RawMessage
+
+			if name == "index" {
+				if !scanner.Scan() {
+					f.write(w, http.StatusBadRequest, map[string]string{"error": "missing document"})
+					return
+				}
+
+				document = append(document, scanner.Bytes()...)
+			}
+
+			item := map[string]interface{}{"_index": meta.Index, "_id": meta.ID}
+			indexes := f.resolve(meta.Index)
+
+			switch {
+			case len(indexes) != 1:
+				item["status"] = http.StatusNotFound
+				item["error"] = map[string]string{"type": "index_not_found_exception", "reason": "no such index"}
+			case f.reject[meta.ID] != "":
+				item["status"] = http.StatusBadRequest
+				item["error"] = map[string]string{"type": "mapper_parsing_exception", "reason": f.reject[meta.ID]}
+			case name == "delete":
+				if _, exists := f.indexes[indexes[0]].documents[meta.ID]; exists {
+					delete(f.indexes[indexes[0]].documents, meta.ID)
+					item["status"] = http.StatusOK
+					item["result"] = "deleted"
+				} else {
+					item["status"] = http.StatusNotFound
+					item["result"] = "not_found"
+				}
+			default:
+				f.indexes[indexes[0]].documents[meta.ID] = document
+				item["status"] = http.StatusCreated
+				item["result"] = "created"
+			}
+
+			if item["status"].(int) > http.StatusCreated {
+				errors = true
+			}
+
+			items = append(items, map[string]interface{}{name: item})
+		}
+	}
+
+	f.write(w, http.StatusOK, map[string]interface{}{"took": 1, "errors": errors, "items": items})
+}
+
+// updateAliases apply the actions on the aliases.
+func (f *fakeElasticsearch) updateAliases(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Actions []map[string]struct {
+			Index string `json:"index"`
+			Alias string `json:"alias"`
+		} `json:"actions"`
+	}
+
+	if err := json2.NewDecoder(r.Body).Decode(&body); err != nil {
+		f.write(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	for _, action := range body.Actions {
+		for name, target := range action {
+			switch name {
+			case "add":
+				f.aliases[target.Alias] = target.Index
+			case "remove":
+				delete(f.aliases, target.Alias)
+			case "remove_index":
+				delete(f.indexes, target.Index)
+			default:
+				f.write(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown action %s", name)})
+				return
+			}
+		}
+	}
+
+	f.write(w, http.StatusOK, map[string]interface{}{"acknowledged": true})
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bufio"
+	json2 "encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMeiliSearch is an in-process MeiliSearch keeping the indexes
+// in memory, it implements the routes used by the indexer. The tasks
+// are processed as soon as they are enqueued.
+type fakeMeiliSearch struct {
+	mutex   sync.Mutex
+	server  *httptest.Server
+	indexes map[string]*fakeMeiliSearchIndex
+	tasks   []fakeMeiliSearchTask
+	// Number of requests adding documents.
+	documentRequests int
+}
+
+// fakeMeiliSearchIndex describes an index of the fake MeiliSearch.
+type fakeMeiliSearchIndex struct {
+	primaryKey string
+	createdAt  time.Time
+	settings   json2.RawMessage
+	documents  map[string]json2.RawMessage
+}
+
+// fakeMeiliSearchTask describes a processed task.
+type fakeMeiliSearchTask struct {
+	UID        int64                  `json:"uid"`
+	IndexUID   string                 `json:"indexUid"`
+	Status     string                 `json:"status"`
+	Type       string                 `json:"type"`
+	Error      map[string]interface{} `json:"error,omitempty"`
+	EnqueuedAt time.Time              `json:"enqueuedAt"`
+	StartedAt  time.Time              `json:"startedAt"`
+	FinishedAt time.Time              `json:"finishedAt"`
+}
+
+// newFakeMeiliSearch start a fake MeiliSearch stopped at the end of the test.
+func newFakeMeiliSearch(t *testing.T) *fakeMeiliSearch {
+	fake := &fakeMeiliSearch{indexes: make(map[string]*fakeMeiliSearchIndex)}
+
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(fake.server.Close)
+
+	return fake
+}
+
+// serveHTTP route the requests like MeiliSearch does.
+func (f *fakeMeiliSearch) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	route := r.Method + " " + segments[0]
+
+	if len(segments) > 1 {
+		route += " {}"
+	}
+
+	if len(segments) > 2 {
+		route += " " + segments[2]
+	}
+
+	switch route {
+	case "GET health":
+		f.write(w, http.StatusOK, map[string]string{"status": "available"})
+	case "GET tasks {}":
+		f.getTask(w, segments[1])
+	case "POST indexes":
+		f.createIndex(w, r)
+	case "GET indexes {}":
+		f.getIndex(w, segments[1])
+	case "DELETE indexes {}":
+		f.enqueue(w, segments[1], "indexDeletion", func() error {
+			if _, exists := f.indexes[segments[1]]; !exists {
+				return fmt.Errorf("index_not_found")
+			}
+
+			delete(f.indexes, segments[1])
+
+			return nil
+		})
+	case "PATCH indexes {} settings":
+		f.updateSettings(w, r, segments[1])
+	case "GET indexes {} settings":
+		if index, exists := f.indexes[segments[1]]; exists {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(index.settings)
+		} else {
+			f.indexNotFound(w, segments[1])
+		}
+	case "POST indexes {} documents":
+		f.addDocuments(w, r, segments[1])
+	case "GET indexes {} stats":
+		if index, exists := f.indexes[segments[1]]; exists {
+			f.write(w, http.StatusOK, map[string]interface{}{"numberOfDocuments": len(index.documents), "isIndexing": false})
+		} else {
+			f.indexNotFound(w, segments[1])
+		}
+	case "POST swap-indexes":
+		f.swapIndexes(w, r)
+	default:
+		f.write(w, http.StatusNotFound, map[string]string{"message": "unknown route " + route, "code": "not_found"})
+	}
+}
+
+// write send the value as the JSON response.
+func (f *fakeMeiliSearch) write(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json2.NewEncoder(w).Encode(value)
+}
+
+// indexNotFound write the error returned for a missing index.
+func (f *fakeMeiliSearch) indexNotFound(w http.ResponseWriter, uid string) {
+	f.write(w, http.StatusNotFound, map[string]string{
+		"message": fmt.Sprintf("Index `%s` not found.", uid),
+		"code":    "index_not_found",
+		"type":    "invalid_request",
+		"link":    "https://docs.meilisearch.com/errors#index_not_found",
+	})
+}
+
+// enqueue process the task and answer with its summary,
+// the task fails if process returns an error code.
+func (f *fakeMeiliSearch) enqueue(w http.ResponseWriter, uid, taskType string, process func() error) {
+	now := time.Now().UTC()
+	task := fakeMeiliSearchTask{
+		UID:        int64(len(f.tasks)),
+		IndexUID:   uid,
+		Status:     "succeeded",
+		Type:       taskType,
+		EnqueuedAt: now,
+		StartedAt:  now,
+		FinishedAt: now,
+	}
+
+	if err := process(); err != nil {
+		task.Status = "failed"
+		task.Error = map[string]interface{}{"message": err.Error(), "code": err.Error(), "type": "invalid_request"}
+	}
+
+	f.tasks = append(f.tasks, task)
+
+	f.write(w, http.StatusAccepted, map[string]interface{}{
+		"taskUid":    task.UID,
+		"indexUid":   uid,
+		"status":     "enqueued",
+		"type":       taskType,
+		"enqueuedAt": now,
+	})
+}
+
+// getTask returns a processed task.
+func (f *fakeMeiliSearch) getTask(w http.ResponseWriter, uid string) {
+	for _, task := range f.tasks {
+		if fmt.Sprint(task.UID) == uid {
+			f.write(w, http.StatusOK, task)
+			return
+		}
+	}
+
+	f.write(w, http.StatusNotFound, map[string]string{"message": "task not found", "code": "task_not_found"})
+}
+
+// getIndex returns the description of an index.
+func (f *fakeMeiliSearch) getIndex(w http.ResponseWriter, uid string) {
+	index, exists := f.indexes[uid]
+
+	if !exists {
+		f.indexNotFound(w, uid)
+		return
+	}
+
+	f.write(w, http.StatusOK, map[string]interface{}{
+		"uid":        uid,
+		"primaryKey": index.primaryKey,
+		"createdAt":  index.createdAt,
+		"updatedAt":  index.createdAt,
+	})
+}
+
+// createIndex create an empty index.
+func (f *fakeMeiliSearch) createIndex(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		UID        string `json:"uid"`
+		PrimaryKey string `json:"primaryKey"`
+	}
+
+	if err := json2.NewDecoder(r.Body).Decode(&body); err != nil {
+		f.write(w, http.StatusBadRequest, map[string]string{"message": err.Error(), "code": "bad_request"})
+		return
+	}
+
+	f.enqueue(w, body.UID, "indexCreation", func() error {
+		if _, exists := f.indexes[body.UID]; exists {
+			return fmt.Errorf("index_already_exists")
+		}
+
+		f.indexes[body.UID] = &fakeMeiliSearchIndex{
+			primaryKey: body.PrimaryKey,
+			createdAt:  time.Now().UTC(),
+			settings:   json2.RawMessage("{}"),
+			documents:  make(map[string]json2.RawMessage),
+		}
+
+		return nil
+	})
+}
+
+// updateSettings keep the settings, they are returned as given.
+func (f *fakeMeiliSearch) updateSettings(w http.ResponseWriter, r *http.Request, uid string) {
+	var settings json2.RawMessage
+
+	if err := json2.NewDecoder(r.Body).Decode(&settings); err != nil {
+		f.write(w, http.StatusBadRequest, map[string]string{"message": err.Error(), "code": "bad_request"})
+		return
+	}
+
+	f.enqueue(w, uid, "settingsUpdate", func() error {
+		index, exists := f.indexes[uid]
+
+		if !exists {
+			return fmt.Errorf("index_not_found")
+		}
+
+		index.settings = settings
+
+		return nil
+	})
+}
+
+// addDocuments add the documents of the NDJSON body.
+func (f *fakeMeiliSearch) addDocuments(w http.ResponseWriter, r *http.Request, uid string) {
+	if contentType := r.Header.Get("Content-Type"); contentType != "application/x-ndjson" {
+		f.write(w, http.StatusUnsupportedMediaType, map[string]string{"message": contentType, "code": "invalid_content_type"})
+		return
+	}
+
+	f.documentRequests++
+
+	var documents []json2.RawMessage
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		documents = append(documents, append(json2.RawMessage(nil), scanner.Bytes()...))
+	}
+
+	f.enqueue(w, uid, "documentAdditionOrUpdate", func() error {
+		index, exists := f.indexes[uid]
+
+		if !exists {
+			return fmt.Errorf("index_not_found")
+		}
+
+		for _, document := range documents {
+			var fields map[string]interface{}
+
+			if err := json2.Unmarshal(document, &fields); err != nil {
+				return fmt.Errorf("malformed_payload")
+			}
+
+			index.documents[fmt.Sprint(fields[index.primaryKey])] = document
+		}
+
+		return nil
+	})
+}
+
+// swapIndexes swap the content of the pairs of indexes.
+func (f *fakeMeiliSearch) swapIndexes(w http.ResponseWriter, r *http.Request) {
+	var swaps []struct {
+		Indexes []string `json:"indexes"`
+	}
+
+	if err := json2.NewDecoder(r.Body).Decode(&swaps); err != nil {
+		f.write(w, http.StatusBadRequest, map[string]string{"message": err.Error(), "code": "bad_request"})
+		return
+	}
+
+	f.enqueue(w, "", "indexSwap", func() error {
+		for _, swap := range swaps {
+			first, second := f.indexes[swap.Indexes[0]], f.indexes[swap.Indexes[1]]
+
+			if first == nil || second == nil {
+				return fmt.Errorf("index_not_found")
+			}
+
+			f.indexes[swap.Indexes[0]], f.indexes[swap.Indexes[1]] = second, first
+		}
+
+		return nil
+	})
+}
@@ -1,18 +1,16 @@
 module tatoeba-indexer
 
-go 1.15
+go 1.16
 
 require (
 	github.com/cavaliercoder/grab v2.0.0+incompatible
 	github.com/cenkalti/backoff v2.2.1+incompatible
-	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/dsnet/compress v0.0.1 // indirect
-	github.com/elastic/go-elasticsearch/v7 v7.10.0
+	github.com/elastic/go-elasticsearch/v7 v7.17.10
 	github.com/fatih/color v1.10.0
 	github.com/golang/snappy v0.0.1 // indirect
 	github.com/integrii/flaggy v1.4.4
-	github.com/klauspost/compress v1.10.10 // indirect
-	github.com/meilisearch/meilisearch-go v0.13.1
+	github.com/meilisearch/meilisearch-go v0.29.0
 	github.com/mholt/archiver v3.1.1+incompatible
 	github.com/nwaples/rardecode v1.1.0 // indirect
 	github.com/pierrec/lz4 v2.0.5+incompatible // indirect
@@ -20,4 +18,5 @@ require (
 	github.com/xi2/xz v0.0.0-20171230120015-48954b6210f8 // indirect
 	golang.org/x/sys v0.0.0-20201202213521-69691e467435 // indirect
 	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -5,13 +5,15 @@ import (
 	"context"
 	json2 "encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/cenkalti/backoff"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/fatih/color"
 )
 
 // Elasticsearch will index the sentences
@@ -19,31 +21,49 @@ import (
 type Elasticsearch struct {
 	client                 *elasticsearch.Client
 	bulkIndexer            esutil.BulkIndexer
-	host                   string
+	host, index            string
 	numWorkers, flushBytes int
-}
+	keepIndexes            int
 
-// Init the MeiliSearch client.
-func (e *Elasticsearch) Init() {
-	// Format the host.
-	var host string
+	// Name of the alias of the live index, the index is the one built.
+	indexName string
 
-	if strings.HasPrefix(e.host, "http://") || strings.HasPrefix(e.host, "https://") {
-		host = e.host
-	} else {
-		host = "http://" + e.host
-	}
+	// Index settings.
+	shards, replicas       int
+	refreshInterval, codec string
+	forceMergeAfterLoad    bool
+	suggest                bool
+
+	// Failures handling.
+	deadLetter     *DeadLetter
+	deadLetterPath string
+	maxFailures    int
+	flushedBytes   *uint64
+	startedAt      time.Time
+
+	// Progress of the run, to resume it when interrupted.
+	checkpoint *Checkpoint
+
+	// Security options, the secrets are read from the environment.
+	username, cloudID             string
+	caFingerprint                 string
+	caCert, clientCert, clientKey string
+	passwordRequired              bool
+	insecureSkipVerify            bool
 
+	// Output of the messages and of the progress.
+	output io.Writer
+}
+
+// Connect create the Elasticsearch client.
+func (e *Elasticsearch) Connect() error {
 	// Declare the backoff function.
 	retryBackoff := backoff.NewExponentialBackOff()
 
-	// Declare the client init instance error.
-	var err error
-
-	// Create an Elasticsearch client.
-	e.client, err = elasticsearch.NewClient(elasticsearch.Config{
+	// Declare the client configuration.
+	config := elasticsearch.Config{
 		RetryOnStatus: []int{502, 503, 504, 429},
-		Addresses:     []string{host},
+		Addresses:     e.addresses(),
 		RetryBackoff: func(i int) time.Duration {
 			if i == 1 {
 				retryBackoff.Reset()
@@ -51,36 +71,143 @@ func (e *Elasticsearch) Init() {
 			return retryBackoff.NextBackOff()
 		},
 		MaxRetries: 5,
-	})
+	}
+
+	// Set the credentials and the TLS options.
+	if err := e.configureSecurity(&config); err != nil {
+		return err
+	}
+
+	// Declare the client init instance error.
+	var err error
+
+	// Create an Elasticsearch client.
+	e.client, err = elasticsearch.NewClient(config)
 
 	if err != nil {
-		log.Fatalf("Error creating the client: %s", err)
+		return fmt.Errorf("cannot create the client: %s", err)
 	}
 
-	// Delete the index
-	res, err := e.client.Indices.Delete([]string{IndexName}, e.client.Indices.Delete.WithIgnoreUnavailable(true))
+	// Elasticsearch 8 is used with the compatibility headers,
+	// so the same requests work on both versions.
+	major, version, err := e.serverVersion()
 
-	if err != nil || res.IsError() {
-		log.Fatalf("Cannot delete index: %s", err)
+	if err != nil {
+		return err
 	}
 
-	res.Body.Close()
+	if major >= 8 {
+		config.EnableCompatibilityMode = true
+
+		e.client, err = elasticsearch.NewClient(config)
+
+		if err != nil {
+			return fmt.Errorf("cannot create the client: %s", err)
+		}
+	}
+
+	fmt.Fprintf(e.output, "Elasticsearch version %s detected.\n", version)
+
+	// Print the current instance.
+	if config.CloudID != "" {
+		fmt.Fprintln(e.output, "Indexing on Elasticsearch on the cloud deployment.")
+	} else {
+		fmt.Fprintf(e.output, "Indexing on Elasticsearch on the host \"%s\".\n", strings.Join(config.Addresses, ", "))
+	}
+
+	return nil
+}
+
+// Init the Elasticsearch client and a new versioned index.
+func (e *Elasticsearch) Init() error {
+	// Create the client.
+	if err := e.Connect(); err != nil {
+		return err
+	}
+
+	// Build into a new index, the alias will be moved
+	// to it once the sentences are indexed.
+	e.index = newVersionedIndex(e.indexName)
 
-	// Re-create the index
-	res, err = e.client.Indices.Create(IndexName)
+	// Create the mapping depending of the installed analysis plugins
+	// with the settings tuned for the bulk load.
+	plugins, err := e.installedPlugins()
 
 	if err != nil {
-		log.Fatalf("Cannot create index: %s", err)
+		return err
 	}
 
-	if res.IsError() {
-		log.Fatalf("Cannot create index: %s", res)
+	body := elasticsearchMapping(plugins, e.suggest)
+	settings := e.bulkLoadSettings()
+
+	if e.suggest {
+		settings["analysis"] = elasticsearchSuggestAnalysis()
+	}
+
+	body["settings"] = settings
+
+	mapping, err := json2.Marshal(body)
+
+	if err != nil {
+		return fmt.Errorf("cannot encode the mapping: %s", err)
+	}
+
+	// Create the index
+	res, err := e.client.Indices.Create(e.index, e.client.Indices.Create.WithBody(bytes.NewReader(mapping)))
+
+	if err := decodeResponse(res, err, "create index", nil); err != nil {
+		return err
+	}
+
+	// The failures of the new index replace the ones of the previous run.
+	e.deadLetter = NewDeadLetter(e.deadLetterPath)
+
+	if err := e.checkpoint.SetBuildIndex(e.index); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.output, "Building the index \"%s\".\n", e.index)
+
+	return nil
+}
+
+// Resume the build of the index of an interrupted run.
+func (e *Elasticsearch) Resume() error {
+	// Create the client.
+	if err := e.Connect(); err != nil {
+		return err
+	}
+
+	e.index = e.checkpoint.BuildIndex
+
+	// The index must still exist to continue filling it.
+	res, err := e.client.Indices.Exists([]string{e.index})
+
+	if err != nil {
+		return fmt.Errorf("cannot check the index: %s", err)
 	}
 
 	res.Body.Close()
 
+	if res.StatusCode != 200 {
+		return fmt.Errorf("the index \"%s\" of the interrupted run does not exist anymore", e.index)
+	}
+
+	// Keep the sentences which failed before the interruption.
+	e.deadLetter = ResumeDeadLetter(e.deadLetterPath)
+
+	fmt.Fprintf(e.output, "Resuming the build of the index \"%s\" after %d sentences.\n", e.index, e.checkpoint.Acknowledged)
+
+	return nil
+}
+
+// newBulkIndexer create the bulk indexer writing to the given index,
+// the sentences rejected by Elasticsearch are written to the dead-letter.
+func (e *Elasticsearch) newBulkIndexer(index string, deadLetter *DeadLetter) error {
+	var err error
+
 	e.bulkIndexer, err = esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
-		Index:         IndexName,
+		Index:         index,
 		Client:        e.client,
 		NumWorkers:    e.numWorkers,
 		FlushBytes:    e.flushBytes,
@@ -88,61 +215,260 @@ func (e *Elasticsearch) Init() {
 	})
 
 	if err != nil {
-		log.Fatalf("Error creating the indexer: %s", err)
+		return fmt.Errorf("cannot create the indexer: %s", err)
 	}
 
-	// Print the current instance.
-	fmt.Printf("Indexing on Elasticsearch on the host \"%s\".\n", host)
+	e.deadLetter = deadLetter
+	e.flushedBytes = new(uint64)
+	e.startedAt = time.Now()
+
+	return nil
+}
+
+// add a sentence to the bulk indexer, onDone is called once
+// Elasticsearch indexed or rejected the sentence.
+func (e Elasticsearch) add(ID string, sentence Sentence, onDone func(failed bool)) error {
+	// Create a JSON from the struct.
+	document, err := elasticsearchDocument(sentence)
+
+	if err != nil {
+		return fmt.Errorf("cannot encode sentence %s: %s", ID, err)
+	}
+
+	// Count the bytes sent once the sentence has been flushed.
+	size := uint64(len(document))
+
+	err = e.bulkIndexer.Add(
+		context.Background(),
+		esutil.BulkIndexerItem{
+			Action:     "index",
+			DocumentID: ID,
+			Body:       bytes.NewReader(document),
+			OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
+				atomic.AddUint64(e.flushedBytes, size)
+				onDone(false)
+			},
+			OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+				atomic.AddUint64(e.flushedBytes, size)
+
+				// Keep the reason to write it in the dead-letter file.
+				var reason string
+
+				if err != nil {
+					reason = err.Error()
+				} else {
+					reason = fmt.Sprintf("%s: %s", res.Error.Type, res.Error.Reason)
+				}
+
+				// The dead-letter keeps the sentence, without the field of its language.
+				// It has already been encoded in the document, it can't fail.
+				sentenceAsJSON, _ := json2.Marshal(sentence)
+
+				// A failure writing the file is returned when it's closed.
+				e.deadLetter.Write(ID, reason, sentenceAsJSON)
+				onDone(true)
+			},
+		},
+	)
+
+	if err != nil {
+		return fmt.Errorf("cannot add sentence %s: %s", ID, err)
+	}
+
+	return nil
+}
+
+// close the bulk indexer, print its statistics and returns
+// the number of failed sentences.
+func (e Elasticsearch) close() (int, error) {
+	// Close the indexer, the workers send the last sentences.
+	if err := e.bulkIndexer.Close(context.Background()); err != nil {
+		e.deadLetter.Abort()
+		return 0, fmt.Errorf("cannot close the indexer: %s", err)
+	}
+
+	if err := e.deadLetter.Close(); err != nil {
+		return 0, err
+	}
+
+	// Print the statistics.
+	stats := e.bulkIndexer.Stats()
+
+	fmt.Fprintln(e.output)
+	fmt.Fprintf(e.output, "Added: %d, indexed: %d, failed: %d\n", stats.NumAdded, stats.NumIndexed, stats.NumFailed)
+	fmt.Fprintf(e.output, "Requests: %d, flushed: %d bytes, duration: %s\n", stats.NumRequests, atomic.LoadUint64(e.flushedBytes), time.Since(e.startedAt).Round(time.Second))
+
+	failed := e.deadLetter.Count()
+
+	if failed > 0 {
+		printColor(e.output, color.FgRed, "%d sentences failed, they have been written to \"%s\".", failed, e.deadLetterPath)
+	}
+
+	// Stop with an error when there are too many failures.
+	if failed > e.maxFailures {
+		return failed, fmt.Errorf("the number of failures exceeds the maximum of %d", e.maxFailures)
+	}
+
+	return failed, nil
+}
+
+// abort close the bulk indexer of a run which failed, so its workers
+// stop. The dead-letter file is kept like the one of an interrupted run.
+func (e Elasticsearch) abort() {
+	_ = e.bulkIndexer.Close(context.Background())
+
+	if e.deadLetter != nil {
+		e.deadLetter.Abort()
+	}
 }
 
 // Index sentences to the Elasticsearch instance.
-func (e Elasticsearch) Index(sentences map[string]Sentence) {
+func (e Elasticsearch) Index(sentences map[string]Sentence) error {
 	// Store the total of sentences.
 	totalSentences := len(sentences)
 
+	// Index in the order of the IDs, skipping the sentences
+	// acknowledged before an interruption.
+	IDs := SortedIDs(sentences)
+	start := 0
+
+	if e.checkpoint != nil {
+		start = e.checkpoint.Acknowledged
+	}
+
+	// Create the bulk indexer once the sentences are loaded,
+	// nothing can fail before it's closed.
+	if err := e.newBulkIndexer(e.index, e.deadLetter); err != nil {
+		return err
+	}
+
+	// The workers acknowledge the sentences out of order,
+	// the checkpoint only moves forward by complete chunks.
+	tracker := newChunkTracker(e.checkpoint, start, totalSentences, checkpointChunkSize)
+
 	// i represent the current index of the loop.
-	i := 1
+	i := uint64(start)
 
 	// Loop over all sentences and index them.
-	for index, sentence := range sentences {
-		// Create a JSON from the struct.
-		sentenceAsJSON, err := json2.Marshal(sentence)
+	for position := start; position < totalSentences; position++ {
+		position := position
+		ID := IDs[position]
+
+		// Add an item to the BulkIndexer
+		err := e.add(ID, sentences[ID], func(failed bool) {
+			tracker.Done(position, failed)
+
+			// Log to the terminal the advance.
+			if !failed {
+				fmt.Fprintf(e.output, "\rIndexing sentences %d of %d", atomic.AddUint64(&i, 1), totalSentences)
+			}
+		})
 
 		if err != nil {
-			log.Fatalf("Cannot encode sentence %d: %s", sentence.ID, err)
+			e.abort()
+			return err
 		}
+	}
 
-		// Add an item to the BulkIndexer
-		err = e.bulkIndexer.Add(
-			context.Background(),
-			esutil.BulkIndexerItem{
-				Action:     "index",
-				DocumentID: index,
-				Body:       bytes.NewReader(sentenceAsJSON),
-				OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
-					// Log to the terminal the advance.
-					fmt.Printf("\rIndexing sentences %d of %d", i, totalSentences)
-
-					// Incremente the counter.
-					i++
-				},
-				OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
-					if err != nil {
-						log.Printf("ERROR: %s", err)
-					} else {
-						log.Printf("ERROR: %s: %s", res.Error.Type, res.Error.Reason)
-					}
-				},
-			},
-		)
+	// Close the indexer, the failures include the ones
+	// of the run interrupted.
+	if _, err := e.close(); err != nil {
+		return err
+	}
 
-		if err != nil {
-			log.Fatalf("Unexpected error: %s", err)
+	if err := tracker.Err(); err != nil {
+		return err
+	}
+
+	failed := tracker.Failed()
+
+	// Merge the segments before the replicas are allocated.
+	if e.forceMergeAfterLoad {
+		if err := e.forceMerge(); err != nil {
+			return err
 		}
 	}
 
-	// Close the indexer
-	if err := e.bulkIndexer.Close(context.Background()); err != nil {
-		log.Fatalf("Unexpected error: %s", err)
+	// Restore the replicas and the refresh interval.
+	if err := e.restoreSettings(); err != nil {
+		return err
+	}
+
+	// Keep the alias on the previous index if some sentences are missing.
+	count, err := e.countDocuments(e.index)
+
+	if err != nil {
+		return err
+	}
+
+	if count != totalSentences-failed {
+		return fmt.Errorf("the index \"%s\" contains %d of %d sentences, the alias \"%s\" has not been moved", e.index, count, totalSentences-failed, e.indexName)
+	}
+
+	// Switch the alias to the new index and clean the old ones.
+	if err := e.markComplete(e.index); err != nil {
+		return err
+	}
+
+	if err := e.moveAlias(e.index); err != nil {
+		return err
+	}
+
+	return e.deleteOldIndexes()
+}
+
+// RetryFailed index again the sentences of the dead-letter file
+// in the index used by the alias.
+func (e *Elasticsearch) RetryFailed() error {
+	failedSentences, err := ReadDeadLetter(e.deadLetterPath)
+
+	if err != nil {
+		return err
 	}
+
+	// A sentence can have failed several times, keep its last version.
+	sentences := make(map[string]Sentence)
+
+	for _, failed := range failedSentences {
+		// Decode the sentence to add the field of its language.
+		var sentence Sentence
+
+		if err := json2.Unmarshal(failed.Sentence, &sentence); err != nil {
+			return fmt.Errorf("cannot decode the failed sentence %s: %s", failed.ID, err)
+		}
+
+		sentences[failed.ID] = sentence
+	}
+
+	if len(sentences) == 0 {
+		fmt.Fprintln(e.output, "There is no failed sentence to retry.")
+		return nil
+	}
+
+	// The sentences failing again replace the file once they are all sent,
+	// the file is kept as it is if the run is interrupted.
+	if err := e.newBulkIndexer(e.indexName, NewDeadLetter(e.deadLetterPath)); err != nil {
+		return err
+	}
+
+	var i uint64
+
+	for ID, sentence := range sentences {
+		err := e.add(ID, sentence, func(failed bool) {
+			if failed {
+				return
+			}
+
+			fmt.Fprintf(e.output, "\rIndexing sentences %d of %d", atomic.AddUint64(&i, 1), len(sentences))
+		})
+
+		if err != nil {
+			e.abort()
+			return err
+		}
+	}
+
+	_, err = e.close()
+
+	return err
 }
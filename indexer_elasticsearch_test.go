@@ -0,0 +1,263 @@
+package main
+
+import (
+	json2 "encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v7"
+)
+
+// newTestElasticsearch returns an indexer using the fake Elasticsearch.
+func newTestElasticsearch(t *testing.T, fake *fakeElasticsearch) *Elasticsearch {
+	return &Elasticsearch{
+		host:            fake.server.URL,
+		indexName:       IndexName,
+		numWorkers:      2,
+		flushBytes:      256,
+		keepIndexes:     1,
+		shards:          1,
+		replicas:        1,
+		refreshInterval: "1s",
+		codec:           "default",
+		deadLetterPath:  filepath.Join(t.TempDir(), "failed_sentences.jsonl"),
+		output:          os.Stdout,
+	}
+}
+
+func TestElasticsearchInitIndex(t *testing.T) {
+	sentences := fixtureSentences(t)
+	fake := newFakeElasticsearch(t)
+	client := newTestElasticsearch(t, fake)
+
+	initIndex(t, client, sentences)
+
+	// The alias points to the built index.
+	index, exists := fake.aliases[IndexName]
+
+	if !exists || index != client.index {
+		t.Fatalf("The alias points to \"%s\", want \"%s\"", index, client.index)
+	}
+
+	built := fake.indexes[index]
+
+	// The index is created for the bulk load, then the settings are restored.
+	settings := built.created["settings"].(map[string]interface{})["index"].(map[string]interface{})
+
+	if settings["number_of_replicas"] != float64(0) || settings["refresh_interval"] != "-1" {
+		t.Errorf("The index has been created with the settings %v", settings)
+	}
+
+	if len(built.settings) != 1 || built.settings[0]["index"].(map[string]interface{})["refresh_interval"] != "1s" {
+		t.Errorf("The settings restored are %v", built.settings)
+	}
+
+	if len(built.documents) != len(sentences) {
+		t.Fatalf("%d documents have been indexed, want %d", len(built.documents), len(sentences))
+	}
+
+	for ID, sentence := range sentences {
+		var indexed Sentence
+		var fields map[string]interface{}
+
+		if err := json2.Unmarshal(built.documents[ID], &indexed); err != nil {
+			t.Fatal(err)
+		}
+
+		if document := encodeSentence(t, indexed); document != encodeSentence(t, sentence) {
+			t.Errorf("The document %s is %s, want %s", ID, document, encodeSentence(t, sentence))
+		}
+
+		// The content is only copied to the field of the language of the sentence.
+		if err := json2.Unmarshal(built.documents[ID], &fields); err != nil {
+			t.Fatal(err)
+		}
+
+		if fields["content_"+sentence.Language] != sentence.Content {
+			t.Errorf("The document %s has no field content_%s", ID, sentence.Language)
+		}
+
+		for name := range fields {
+			if strings.HasPrefix(name, "content_") && name != "content_"+sentence.Language {
+				t.Errorf("The document %s of language %s has the field %s", ID, sentence.Language, name)
+			}
+		}
+	}
+}
+
+func TestElasticsearchRejectedSentences(t *testing.T) {
+	sentences := fixtureSentences(t)
+	fake := newFakeElasticsearch(t)
+	fake.reject["7"] = "failed to parse field [content]"
+
+	client := newTestElasticsearch(t, fake)
+	client.maxFailures = 1
+
+	initIndex(t, client, sentences)
+
+	// The alias is moved, the rejected sentence is in the dead-letter file.
+	if documents := len(fake.indexes[fake.aliases[IndexName]].documents); documents != len(sentences)-1 {
+		t.Errorf("%d documents have been indexed, want %d", documents, len(sentences)-1)
+	}
+
+	failed, err := ReadDeadLetter(client.deadLetterPath)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(failed) != 1 || failed[0].ID != "7" || !strings.Contains(failed[0].Reason, "failed to parse field") {
+		t.Fatalf("The dead-letter file contains %v", failed)
+	}
+
+	if string(failed[0].Sentence) != encodeSentence(t, sentences["7"]) {
+		t.Errorf("The dead-letter sentence is %s", failed[0].Sentence)
+	}
+}
+
+func TestElasticsearchDeleteReportsFailures(t *testing.T) {
+	sentences := fixtureSentences(t)
+	fake := newFakeElasticsearch(t)
+	client := newTestElasticsearch(t, fake)
+
+	initIndex(t, client, sentences)
+
+	// The sentence 2 can't be deleted, the sentence 42 doesn't exist.
+	fake.reject["2"] = "cluster block"
+
+	failed, err := client.Delete([]string{"1", "2", "42"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(failed) != 1 || failed[0] != "2" {
+		t.Errorf("Delete() = %v, want [2]", failed)
+	}
+
+	documents := fake.indexes[fake.aliases[IndexName]].documents
+
+	if _, exists := documents["1"]; exists {
+		t.Error("The sentence 1 has not been deleted")
+	}
+
+	if _, exists := documents["2"]; !exists {
+		t.Error("The rejected sentence 2 has been deleted")
+	}
+}
+
+func TestElasticsearchPushReportsFailures(t *testing.T) {
+	sentences := fixtureSentences(t)
+	fake := newFakeElasticsearch(t)
+	client := newTestElasticsearch(t, fake)
+	client.maxFailures = 1
+
+	initIndex(t, client, sentences)
+
+	fake.reject["3"] = "failed to parse field [content]"
+
+	failed, err := client.Push(sentences)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(failed) != 1 || failed[0] != "3" {
+		t.Errorf("Push() = %v, want [3]", failed)
+	}
+}
+
+func TestElasticsearchRollbackSkipsIncompleteIndexes(t *testing.T) {
+	fake := newFakeElasticsearch(t)
+
+	// An index of an older version, a complete one and a failed build.
+	fake.addIndex(IndexName+"-20200101000000", nil)
+	fake.addIndex(IndexName+"-20210101000000", true)
+	fake.addIndex(IndexName+"-20220101000000", false)
+	fake.addIndex(IndexName+"-20230101000000", true)
+	fake.aliases[IndexName] = IndexName + "-20230101000000"
+
+	client := newTestElasticsearch(t, fake)
+
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if index := fake.aliases[IndexName]; index != IndexName+"-20210101000000" {
+		t.Errorf("The alias points to \"%s\", want the complete index \"%s-20210101000000\"", index, IndexName)
+	}
+}
+
+func TestElasticsearchKeepCountsCompleteIndexes(t *testing.T) {
+	sentences := fixtureSentences(t)
+	fake := newFakeElasticsearch(t)
+
+	fake.addIndex(IndexName+"-20200101000000", nil)
+	fake.addIndex(IndexName+"-20210101000000", true)
+	fake.addIndex(IndexName+"-20220101000000", false)
+	fake.aliases[IndexName] = IndexName + "-20210101000000"
+
+	client := newTestElasticsearch(t, fake)
+	client.keepIndexes = 2
+
+	initIndex(t, client, sentences)
+
+	// The 2 complete indexes are kept, the failed build is deleted.
+	for name, kept := range map[string]bool{"20200101000000": true, "20210101000000": true, "20220101000000": false} {
+		if _, exists := fake.indexes[IndexName+"-"+name]; exists != kept {
+			t.Errorf("The index \"%s-%s\" exists: %t, want %t", IndexName, name, exists, kept)
+		}
+	}
+
+	if complete := fake.indexes[client.index].meta["complete"]; complete != true {
+		t.Errorf("The built index is marked complete: %v", complete)
+	}
+}
+
+func TestElasticsearchPasswordFromEnvironmentWithoutTerminal(t *testing.T) {
+	previous, set := os.LookupEnv(envElasticsearchPassword)
+	os.Setenv(envElasticsearchPassword, "changeme")
+
+	t.Cleanup(func() {
+		if set {
+			os.Setenv(envElasticsearchPassword, previous)
+		} else {
+			os.Unsetenv(envElasticsearchPassword)
+		}
+	})
+
+	// The tests don't run in a terminal, the password can't be asked.
+	var config elasticsearch.Config
+
+	client := &Elasticsearch{passwordRequired: true}
+
+	if err := client.configureSecurity(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Password != "changeme" {
+		t.Errorf("password = %q, want the one of %s", config.Password, envElasticsearchPassword)
+	}
+}
+
+func TestElasticsearchFingerprintOwnTransport(t *testing.T) {
+	var config elasticsearch.Config
+
+	client := &Elasticsearch{caFingerprint: "0123456789abcdef"}
+
+	if err := client.configureSecurity(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	// The client changes the dialer of the transport to check the fingerprint.
+	if config.Transport == nil || config.Transport == http.DefaultTransport {
+		t.Error("The fingerprint is checked with the default transport of the process")
+	}
+}
@@ -1,33 +1,54 @@
 package main
 
 import (
-	json2 "encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"io/ioutil"
 	"os"
+	"os/exec"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/meilisearch/meilisearch-go"
-	"golang.org/x/term"
 )
 
 // MeiliSearch will index the sentences
 // on a given MeiliSearch instance.
 type MeiliSearch struct {
-	client         meilisearch.ClientInterface
+	client         meilisearch.ServiceManager
 	host, APIKey   string
+	index          string
 	APIKeyRequired bool
+	APIKeyFile     string
+	APIKeyCommand  string
+	settingsPath   string
+	settings       *meilisearch.Settings
+
+	// Name of the live index, the index is the one built.
+	indexName string
+
+	// Batching options.
+	batchSize, maxPendingTasks int
+	compression                string
+
+	// Progress of the run, to resume it when interrupted.
+	checkpoint *Checkpoint
+
+	// Output of the messages and of the progress.
+	output io.Writer
 }
 
-// totalSentencesToIndexByRow define the total of sentences
-// to index each bulk.
-const totalSentencesToIndexByRow = 10000
+// envMeiliSearchAPIKey is the environment variable holding the API key.
+const envMeiliSearchAPIKey = "MEILISEARCH_API_KEY"
 
-// Init the MeiliSearch client and the index.
-func (m *MeiliSearch) Init() {
+// taskPollInterval define the interval between two checks
+// of the status of a task.
+const taskPollInterval = 500 * time.Millisecond
+
+// Connect create the MeiliSearch client.
+func (m *MeiliSearch) Connect() error {
 	// Format the host.
 	var host string
 
@@ -37,135 +58,343 @@ func (m *MeiliSearch) Init() {
 		host = "http://" + m.host
 	}
 
-	// Ask the API key if needed.
-	if m.APIKeyRequired {
-		m.askAPIKey()
+	// Find the API key from the environment, a file, a command or the terminal.
+	if err := m.resolveAPIKey(); err != nil {
+		return err
 	}
 
-	// Create a MeiliSearch client.
-	m.client = meilisearch.NewClient(meilisearch.Config{
-		Host:   host,
-		APIKey: m.APIKey,
-	})
+	// Create a MeiliSearch client, the API key is sent
+	// as a bearer token.
+	options := []meilisearch.Option{meilisearch.WithAPIKey(m.APIKey)}
 
-	// Check if the index exist.
-	if _, err := m.client.Indexes().Get(IndexName); err != nil {
-		// The index doesn't exist, create it.
-		m.createIndex()
+	// Compress the payloads sent to MeiliSearch.
+	if m.compression != "" && m.compression != "none" {
+		options = append(options, meilisearch.WithContentEncoding(meilisearch.ContentEncoding(m.compression), meilisearch.BestSpeed))
 	}
 
-	// Set the searchable attributes.
-	m.setSearchableAttributes()
+	m.client = meilisearch.New(host, options...)
 
 	// Print the current instance.
-	fmt.Printf("Indexing on MeiliSearch on the host \"%s\".\n", host)
+	fmt.Fprintf(m.output, "Indexing on MeiliSearch on the host \"%s\".\n", host)
+
+	return nil
 }
 
-// createIndex will create the Tatoeba index for Meilisearch.
-func (m MeiliSearch) createIndex() {
-	// Create an index if the index does not exist.
-	_, err := m.client.Indexes().Create(meilisearch.CreateIndexRequest{
-		Name: strings.Title(IndexName),
-		UID:  IndexName,
-	})
+// Init the MeiliSearch client and the index to build into.
+func (m *MeiliSearch) Init() error {
+	// Create the client.
+	if err := m.Connect(); err != nil {
+		return err
+	}
+
+	// Build into a temporary index, it will be swapped
+	// with the live one once the sentences are indexed.
+	m.index = m.indexName + "_tmp"
+
+	// Remove the leftover of a failed run.
+	exists, err := m.indexExists(m.index)
+
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		if _, err := m.waitForTask(m.client.DeleteIndex(m.index)); err != nil {
+			return err
+		}
+	}
+
+	if err := m.createIndex(m.index); err != nil {
+		return err
+	}
+
+	// Set the settings of the index.
+	if m.settings, err = readMeiliSearchSettings(m.settingsPath); err != nil {
+		return err
+	}
+
+	if err := m.applySettings(m.index); err != nil {
+		return err
+	}
+
+	if err := m.checkpoint.SetBuildIndex(m.index); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(m.output, "Building the index \"%s\".\n", m.index)
+
+	return nil
+}
+
+// Resume the build of the index of an interrupted run.
+func (m *MeiliSearch) Resume() error {
+	// Create the client.
+	if err := m.Connect(); err != nil {
+		return err
+	}
+
+	m.index = m.checkpoint.BuildIndex
+
+	// The index must still exist to continue filling it.
+	exists, err := m.indexExists(m.index)
 
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	if !exists {
+		return fmt.Errorf("the index \"%s\" of the interrupted run does not exist anymore", m.index)
 	}
+
+	fmt.Fprintf(m.output, "Resuming the build of the index \"%s\" after %d sentences.\n", m.index, m.checkpoint.Acknowledged)
+
+	return nil
 }
 
-// setSearchableAttributes will set the searchable attributes.
-func (m MeiliSearch) setSearchableAttributes() {
-	searchableAttributes := []string{"id", "language", "content", "username"}
+// indexExists check if the index exists.
+func (m MeiliSearch) indexExists(uid string) (bool, error) {
+	if _, err := m.client.GetIndex(uid); err != nil {
+		if !isMeiliSearchError(err, "index_not_found") {
+			return false, err
+		}
 
-	m.client.Settings(IndexName).UpdateSearchableAttributes(searchableAttributes)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// swapIndex replace the live index by the built one, and delete
+// the previous version of the live index.
+func (m MeiliSearch) swapIndex(totalSentences int) error {
+	// Verify all the sentences are in the built index.
+	stats, err := m.client.Index(m.index).GetStats()
+
+	if err != nil {
+		return err
+	}
+
+	if stats.NumberOfDocuments != int64(totalSentences) {
+		fmt.Fprintln(m.output)
+		return fmt.Errorf("the index \"%s\" contains %d of %d sentences, it has not been swapped with \"%s\"", m.index, stats.NumberOfDocuments, totalSentences, m.indexName)
+	}
+
+	// Both indexes must exist to be swapped.
+	exists, err := m.indexExists(m.indexName)
+
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		if err := m.createIndex(m.indexName); err != nil {
+			return err
+		}
+	}
+
+	_, err = m.waitForTask(m.client.SwapIndexes([]*meilisearch.SwapIndexesParams{
+		{Indexes: []string{m.indexName, m.index}},
+	}))
+
+	if err != nil {
+		return err
+	}
+
+	// The built index now contains the previous sentences.
+	if _, err := m.waitForTask(m.client.DeleteIndex(m.index)); err != nil {
+		return err
+	}
+
+	printColor(m.output, color.FgGreen, "\nThe index \"%s\" has been swapped with \"%s\".", m.index, m.indexName)
+
+	return nil
+}
+
+// isMeiliSearchError check if the error has been returned
+// by the MeiliSearch API with the given code.
+func isMeiliSearchError(err error, code string) bool {
+	meiliSearchError, ok := err.(*meilisearch.Error)
+
+	return ok && meiliSearchError.MeilisearchApiError.Code == code
+}
+
+// waitForTask wait until the task has been processed and returns an
+// error if the task or the request creating it failed.
+func (m MeiliSearch) waitForTask(taskInfo *meilisearch.TaskInfo, err error) (*meilisearch.Task, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := m.client.WaitForTask(taskInfo.TaskUID, taskPollInterval)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if task.Status == meilisearch.TaskStatusFailed {
+		return nil, fmt.Errorf("the task %d failed: %s (%s)", task.UID, task.Error.Message, task.Error.Code)
+	}
+
+	return task, nil
+}
+
+// createIndex will create the Tatoeba index for Meilisearch.
+func (m MeiliSearch) createIndex(uid string) error {
+	_, err := m.waitForTask(m.client.CreateIndex(&meilisearch.IndexConfig{
+		Uid:        uid,
+		PrimaryKey: "id",
+	}))
+
+	return err
 }
 
 // Index sentences to the MeiliSearch instance.
-func (m MeiliSearch) Index(sentences map[string]Sentence) {
+func (m MeiliSearch) Index(sentences map[string]Sentence) error {
 	// Store the total of sentences.
 	totalSentences := len(sentences)
 
-	// Get the index documents from the client.
-	index := m.client.Documents(IndexName)
+	// Get the index to build from the client.
+	index := m.client.Index(m.index)
+
+	// Index in the order of the IDs, skipping the sentences
+	// acknowledged before an interruption.
+	IDs := SortedIDs(sentences)
+	start := 0
 
-	// i represent the current index of the loop.
-	i := 1
+	if m.checkpoint != nil {
+		start = m.checkpoint.Acknowledged
+	}
 
-	// Create a map of interfaces to store the sentences to index.
-	var documents []map[string]interface{}
+	// Store the batch of sentences as NDJSON, the buffer is reused
+	// for every batch.
+	var batch []byte
+	batchSize := 0
+
+	// Store the tasks not processed yet, from the oldest to the newest,
+	// with the number of sentences sent once each task is processed.
+	var pendingTasks []*meilisearch.TaskInfo
+	var pendingEnds []int
+
+	// The tasks are processed in order, so the sentences are
+	// acknowledged when the task of their batch is processed.
+	waitForOldestTask := func() error {
+		if _, err := m.waitForTask(pendingTasks[0], nil); err != nil {
+			return err
+		}
 
-	// Loop over all sentences and index them.
-	for _, sentence := range sentences {
-		// Create an empty interface to convert the struct in.
-		var sentenceInterface map[string]interface{}
+		if err := m.checkpoint.Acknowledge(pendingEnds[0], 0); err != nil {
+			return err
+		}
 
-		// Create a JSON from the struct to be able to
-		// convert it into interface.
-		sentenceAsJSON, _ := json2.Marshal(sentence)
+		pendingTasks = pendingTasks[1:]
+		pendingEnds = pendingEnds[1:]
 
-		// Convert the JSON to the interface.
-		json2.Unmarshal(sentenceAsJSON, &sentenceInterface)
+		return nil
+	}
+
+	// Loop over all sentences and index them.
+	for position := start; position < totalSentences; position++ {
+		// i represent the number of sentences added to the batches.
+		i := position + 1
 
-		// Add the sentence interface to documents to index.
-		documents = append(documents, sentenceInterface)
+		// Add the sentence as a line of the batch.
+		batch = append(sentences[IDs[position]].AppendJSON(batch), '\n')
+
+		batchSize++
 
 		// Call the API to add the sentences.
-		if len(documents) == totalSentencesToIndexByRow || i == totalSentences {
+		if batchSize == m.batchSize || i == totalSentences {
 			// Check if the client still working.
-			if err := m.client.Health().Get(); err != nil {
-				color.Red("\nThe server isn't responding anymore... Can't index sentences...")
-				os.Exit(0)
+			if !m.client.IsHealthy() {
+				fmt.Fprintln(m.output)
+				return errors.New("the server isn't responding anymore, the sentences can't be indexed")
 			}
 
-			// Add documents.
-			addResponse, err := index.AddOrReplace(documents)
+			// Add documents without waiting for them to be processed.
+			taskInfo, err := index.AddDocumentsNdjson(batch, "id")
 
 			if err != nil {
-				log.Fatal(err)
+				return err
 			}
 
-			// Reset the sentences array of map.
-			documents = make([]map[string]interface{}, 0)
-
-			// Log to the terminal the advance.
-			fmt.Printf("\rIndexing sentences %d of %d", i, totalSentences)
+			pendingTasks = append(pendingTasks, taskInfo)
+			pendingEnds = append(pendingEnds, i)
 
-			// Wait until the documents has been added by calling
-			// the update API.
-			for {
-				// Wait 2 secondes between every update call.
-				time.Sleep(2 * time.Second)
+			// Reset the batch.
+			batch = batch[:0]
+			batchSize = 0
 
-				// Get the update reponse.
-				response, _ := m.client.Updates(IndexName).Get(addResponse.UpdateID)
+			// Log to the terminal the advance.
+			fmt.Fprintf(m.output, "\rSending sentences %d of %d", i, totalSentences)
 
-				// Continue the indexation when the last update has been processed.
-				if response.Status == meilisearch.UpdateStatusProcessed {
-					break
+			// Wait for the oldest tasks when the queue grows too large.
+			for len(pendingTasks) > m.maxPendingTasks {
+				if err := waitForOldestTask(); err != nil {
+					return err
 				}
 			}
 		}
+	}
+
+	// Wait until all the documents have been added.
+	for j, total := 1, len(pendingTasks); len(pendingTasks) > 0; j++ {
+		fmt.Fprintf(m.output, "%c[2K\rWaiting for the task %d of %d", 27, j, total)
 
-		// Increment the counter.
-		i++
+		if err := waitForOldestTask(); err != nil {
+			return err
+		}
 	}
+
+	fmt.Fprintf(m.output, "%c[2K\rIndexed %d sentences", 27, totalSentences)
+
+	// Replace the live index.
+	return m.swapIndex(totalSentences)
 }
 
-// askAPIKey will prompt in terminal to enter the API key.
-func (m *MeiliSearch) askAPIKey() {
-	// Ask user to enter the api key.
-	fmt.Print("Please enter the API key: ")
+// resolveAPIKey find the API key, in order, from the API key file, the
+// credentials helper command or the environment variable, and from the
+// terminal with --api-key when none of them gives a key.
+func (m *MeiliSearch) resolveAPIKey() error {
+	// Read the key from a file, like a Docker or Kubernetes secret.
+	if m.APIKeyFile != "" {
+		key, err := ioutil.ReadFile(m.APIKeyFile)
 
-	// Ask the user to enter the API key from the terminal.
-	APIKey, err := term.ReadPassword(int(syscall.Stdin))
+		if err != nil {
+			return fmt.Errorf("cannot read the API key file: %s", err)
+		}
 
-	if err != nil {
-		log.Fatal(err)
+		m.APIKey = strings.TrimSpace(string(key))
+		return nil
 	}
 
-	// Store the API key.
-	m.APIKey = string(APIKey)
+	// Ask the key to a credentials helper, the key is its output.
+	if m.APIKeyCommand != "" {
+		command := exec.Command("sh", "-c", m.APIKeyCommand)
+		command.Stderr = os.Stderr
 
-	fmt.Println()
+		key, err := command.Output()
+
+		if err != nil {
+			return fmt.Errorf("the API key command failed: %s", err)
+		}
+
+		m.APIKey = strings.TrimSpace(string(key))
+		return nil
+	}
+
+	m.APIKey = os.Getenv(envMeiliSearchAPIKey)
+
+	// The terminal is only a fallback, a job without terminal
+	// keeps working with the environment variable.
+	if m.APIKey == "" && m.APIKeyRequired && isTerminal() {
+		m.askAPIKey()
+	}
+
+	return nil
+}
+
+// askAPIKey will prompt in terminal to enter the API key.
+func (m *MeiliSearch) askAPIKey() {
+	// Ask the user to enter the API key from the terminal.
+	m.APIKey = askSecret("Please enter the API key: ")
 }
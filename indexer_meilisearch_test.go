@@ -0,0 +1,108 @@
+package main
+
+import (
+	json2 "encoding/json"
+	"os"
+	"testing"
+)
+
+// newTestMeiliSearch returns an indexer using the fake MeiliSearch.
+func newTestMeiliSearch(fake *fakeMeiliSearch) *MeiliSearch {
+	return &MeiliSearch{
+		host:            fake.server.URL,
+		indexName:       IndexName,
+		batchSize:       2,
+		maxPendingTasks: 1,
+		output:          os.Stdout,
+	}
+}
+
+// initIndex build a new index with the sentences.
+func initIndex(t *testing.T, client Indexer, sentences map[string]Sentence) {
+	t.Helper()
+
+	if err := client.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Index(sentences); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMeiliSearchInitIndex(t *testing.T) {
+	sentences := fixtureSentences(t)
+	fake := newFakeMeiliSearch(t)
+
+	// The live index and the leftover of a failed run exist.
+	client := newTestMeiliSearch(fake)
+
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, index := range []string{IndexName, IndexName + "_tmp"} {
+		if err := client.createIndex(index); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client = newTestMeiliSearch(fake)
+	initIndex(t, client, sentences)
+
+	// The built index has been swapped with the live one, then deleted.
+	if _, exists := fake.indexes[IndexName+"_tmp"]; exists {
+		t.Errorf("The index \"%s_tmp\" has not been deleted", IndexName)
+	}
+
+	live := fake.indexes[IndexName]
+
+	var settings map[string]interface{}
+
+	if err := json2.Unmarshal(live.settings, &settings); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, exists := settings["filterableAttributes"]; !exists {
+		t.Errorf("The default settings have not been applied: %s", live.settings)
+	}
+
+	// The sentences are sent by batches of 2.
+	if batches := (len(sentences) + 1) / 2; fake.documentRequests != batches {
+		t.Errorf("The sentences have been sent in %d requests, want %d", fake.documentRequests, batches)
+	}
+
+	if len(live.documents) != len(sentences) {
+		t.Fatalf("%d documents have been indexed, want %d", len(live.documents), len(sentences))
+	}
+
+	for ID, sentence := range sentences {
+		if document := string(live.documents[ID]); document != encodeSentence(t, sentence) {
+			t.Errorf("The document %s is %s, want %s", ID, document, encodeSentence(t, sentence))
+		}
+	}
+}
+
+func TestMeiliSearchAPIKeyFromEnvironmentWithoutTerminal(t *testing.T) {
+	previous, set := os.LookupEnv(envMeiliSearchAPIKey)
+	os.Setenv(envMeiliSearchAPIKey, "masterKey")
+
+	t.Cleanup(func() {
+		if set {
+			os.Setenv(envMeiliSearchAPIKey, previous)
+		} else {
+			os.Unsetenv(envMeiliSearchAPIKey)
+		}
+	})
+
+	// The tests don't run in a terminal, the key can't be asked.
+	client := &MeiliSearch{APIKeyRequired: true}
+
+	if err := client.resolveAPIKey(); err != nil {
+		t.Fatal(err)
+	}
+
+	if client.APIKey != "masterKey" {
+		t.Errorf("API key = %q, want the one of %s", client.APIKey, envMeiliSearchAPIKey)
+	}
+}
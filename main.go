@@ -2,12 +2,19 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"log"
 	"math"
 	"os"
 	"runtime"
+	"strings"
+	"syscall"
+
+	"tatoeba-indexer/tatoeba"
 
 	"github.com/fatih/color"
 	"github.com/integrii/flaggy"
+	"golang.org/x/term"
 )
 
 // Declare the files names.
@@ -27,52 +34,264 @@ const (
 // Declare CLI arguments variables and their defaults.
 var IndexName = "tatoeba"
 var needDownloadFiles = false
+var needVerify = false
+var needRepair = false
+var needDelta = false
+var hashesPath = ""
+var needPrune = false
+var maxDeletePercent = 5.0
+var needForce = false
+var needCheckpoint = false
+var needResume = false
+var checkpointPath = ""
+var fromSnapshotPath = ""
+var targetsPath = ""
+var snapshotPath = "tatoeba.snapshot"
+var configPath = ""
+var profileName = ""
+var targetName = ""
+var languagesFilter = ""
+var skipAudio = false
+var skipIndirectRelations = false
+var skipTranscriptions = false
+var statsFormat = "table"
+var statsTop = 10
+var listenAddress = "127.0.0.1:8080"
 
 // MeiliSearch variables.
 var isAPIKeyRequired = false
+var apiKeyFileMeiliSearch = ""
+var apiKeyCommandMeiliSearch = ""
 var hostMeiliSearch = "127.0.0.1:7700"
+var settingsPathMeiliSearch = ""
+var batchSizeMeiliSearch = 10000
+var maxPendingTasks = 8
+var compressionMeiliSearch = "gzip"
 
 // Elasticsearch variables.
 var hostElasticsearch = "127.0.0.1:9200"
 var numWorkers = int(math.Min(2, float64(runtime.NumCPU())))
 var flushBytes = 1000000
+var keepIndexes = 2
+var shards = 1
+var replicas = 1
+var refreshInterval = "1s"
+var codec = "default"
+var needForceMerge = false
+var needSuggest = false
+var usernameElasticsearch = ""
+var isPasswordRequired = false
+var cloudIDElasticsearch = ""
+var caCertElasticsearch = ""
+var caFingerprintElasticsearch = ""
+var clientCertElasticsearch = ""
+var clientKeyElasticsearch = ""
+var insecureSkipVerify = false
+var deadLetterPath = defaultDeadLetterPath
+var maxFailures = 0
+
+// Declare the subcommands to know which one has been used.
+var meiliSearchSubcommand *flaggy.Subcommand
+var elasticsearchSubcommand *flaggy.Subcommand
+var elasticsearchRollbackSubcommand *flaggy.Subcommand
+var elasticsearchRetryFailedSubcommand *flaggy.Subcommand
+var targetsSubcommand *flaggy.Subcommand
+var buildSubcommand *flaggy.Subcommand
+var statsSubcommand *flaggy.Subcommand
+var serveSubcommand *flaggy.Subcommand
+var configValidateSubcommand *flaggy.Subcommand
+var configPrintSubcommand *flaggy.Subcommand
+
+// engineName is the name of the engine subcommand used.
+var engineName = ""
 
 // parseCLIArguments will parse CLI arguments and populate
-// the variables.
+// the variables. The values of the configuration file and the
+// environment variables are used as defaults of the flags.
 func parseCLIArguments() {
+	// Parse the arguments a first time to find the configuration
+	// file, the profile and the subcommand.
+	defineCLIArguments()
+	flaggy.Parse()
+
+	if meiliSearchSubcommand.Used {
+		engineName = meilisearchName
+	} else if elasticsearchSubcommand.Used {
+		engineName = elasticsearchName
+	}
+
+	// Apply the configuration file, then the environment variables.
+	// The file is only checked by the validate subcommand.
+	if config := LoadConfig(); config != nil && !configValidateSubcommand.Used {
+		config.Apply(engineName)
+	}
+
+	applyEnvironment()
+
+	// Parse the arguments again, the flags override the other values.
+	flaggy.ResetParser()
+	defineCLIArguments()
+	flaggy.Parse()
+
+	// Check if the number of workers is not exceeded.
+	if numWorkers > runtime.NumCPU() {
+		color.Cyan(fmt.Sprintf("You can't define more than %d workers. The value has been changed with the maximum one.", runtime.NumCPU()))
+		numWorkers = runtime.NumCPU()
+	}
+
+	// The sentences are sent and listed by batches.
+	if batchSizeMeiliSearch < 1 {
+		color.Red("The batch size must be at least 1.")
+		os.Exit(1)
+	}
+
+	if statsTop < 0 {
+		color.Red("The number of top contributors and language pairs can't be negative.")
+		os.Exit(1)
+	}
+
+	if statsFormat != "table" && statsFormat != "json" {
+		color.Red("Unknown format \"%s\", use table or json.", statsFormat)
+		os.Exit(1)
+	}
+
+	// The client has no encoder for the other compressions.
+	switch compressionMeiliSearch {
+	case "gzip", "deflate", "br", "none":
+	default:
+		color.Red("Unknown compression \"%s\", use gzip, deflate, br or none.", compressionMeiliSearch)
+		os.Exit(1)
+	}
+
+	// The old indexes are deleted once the alias has been moved.
+	if keepIndexes < 0 {
+		color.Red("The number of old indexes to keep can't be negative.")
+		os.Exit(1)
+	}
+
+	// The client dials the TLS connections itself to check the fingerprint,
+	// without the client certificate.
+	if caFingerprintElasticsearch != "" && clientCertElasticsearch != "" {
+		color.Red("The --ca-fingerprint and --cert options cannot be used together, use --ca-cert instead.")
+		os.Exit(1)
+	}
+}
+
+// defineCLIArguments declare the flags and the subcommands.
+func defineCLIArguments() {
 	// Create the global command.
 	flaggy.String(&IndexName, "i", "index", "index name")
 	flaggy.Bool(&needDownloadFiles, "d", "download-files", "download files needed to index Tatoeba's sentences")
+	flaggy.Bool(&needVerify, "", "verify", "verify all the sentences have been indexed")
+	flaggy.Bool(&needRepair, "", "repair", "index again the missing sentences found by --verify")
+	flaggy.Bool(&needDelta, "", "delta", "only index the sentences changed since the last run")
+	flaggy.String(&hashesPath, "", "hashes", "file storing the hashes of the sentences for --delta")
+	flaggy.Bool(&needPrune, "", "prune", "delete the documents of the sentences removed from Tatoeba")
+	flaggy.Float64(&maxDeletePercent, "", "max-delete", "the maximum percentage of documents deleted without --force")
+	flaggy.Bool(&needForce, "", "force", "delete the documents even above the --max-delete percentage")
+	flaggy.Bool(&needCheckpoint, "", "checkpoint", "save the progress of the run to resume it if interrupted")
+	flaggy.Bool(&needResume, "", "resume", "resume the interrupted run from its checkpoint")
+	flaggy.String(&checkpointPath, "", "checkpoint-file", "file storing the progress of the run for --resume")
+	flaggy.String(&fromSnapshotPath, "", "from-snapshot", "file of parsed sentences to index instead of parsing the files")
+	flaggy.String(&languagesFilter, "", "languages", "comma separated languages of the sentences to index, all by default")
+	flaggy.Bool(&skipAudio, "", "skip-audio", "don't flag the sentences with audio")
+	flaggy.Bool(&skipIndirectRelations, "", "skip-indirect-relations", "don't add the indirect translations")
+	flaggy.Bool(&skipTranscriptions, "", "skip-transcriptions", "don't add the transcriptions")
+	flaggy.String(&configPath, "c", "config", "YAML configuration file, tatoeba.yaml by default if it exists")
+	flaggy.String(&profileName, "p", "profile", "profile of the configuration file to use")
+	flaggy.String(&targetName, "t", "target", "target of the configuration file to index")
 
 	// Create the subcommand for MeiliSearch.
-	meiliSearchSubcommand := flaggy.NewSubcommand(meilisearchName)
+	meiliSearchSubcommand = flaggy.NewSubcommand(meilisearchName)
 	meiliSearchSubcommand.Description = "Index sentences in MeiliSearch.\n\nhttps://www.meilisearch.com"
 
 	// Declare arguments need to provide as CLI arguments.
 	meiliSearchSubcommand.Bool(&isAPIKeyRequired, "", "api-key", "will ask you to enter the API key")
+	meiliSearchSubcommand.String(&apiKeyFileMeiliSearch, "", "api-key-file", "file containing the API key")
+	meiliSearchSubcommand.String(&apiKeyCommandMeiliSearch, "", "api-key-command", "command printing the API key")
 	meiliSearchSubcommand.String(&hostMeiliSearch, "", "host", "host url")
+	meiliSearchSubcommand.String(&settingsPathMeiliSearch, "s", "settings", "JSON file of the index settings")
+	meiliSearchSubcommand.Int(&batchSizeMeiliSearch, "b", "batch-size", "the number of sentences sent by request")
+	meiliSearchSubcommand.Int(&maxPendingTasks, "", "max-pending-tasks", "the number of batches waiting to be processed before sending new ones")
+	meiliSearchSubcommand.String(&compressionMeiliSearch, "", "compression", "the compression of the payloads: gzip, deflate, br or none")
 
 	// Create the subcommand for Elasticsearch.
-	elasticsearchSubcommand := flaggy.NewSubcommand(elasticsearchName)
+	elasticsearchSubcommand = flaggy.NewSubcommand(elasticsearchName)
 	elasticsearchSubcommand.Description = "Index sentences in Elasticsearch.\n\nhttps://www.elastic.co/elasticsearch/"
 
 	// Declare arguments need to provide as CLI arguments.
-	elasticsearchSubcommand.String(&hostElasticsearch, "", "host", "host url")
+	elasticsearchSubcommand.String(&hostElasticsearch, "", "host", "host url, several hosts can be separated by commas")
 	elasticsearchSubcommand.Int(&numWorkers, "w", "workers", fmt.Sprintf("the number of workers. Maximum %d", runtime.NumCPU()))
 	elasticsearchSubcommand.Int(&flushBytes, "b", "flush-bytes", "the flush threshold in bytes")
+	elasticsearchSubcommand.Int(&keepIndexes, "k", "keep", "the number of old indexes to keep for a rollback")
+	elasticsearchSubcommand.Int(&shards, "", "shards", "the number of primary shards")
+	elasticsearchSubcommand.Int(&replicas, "", "replicas", "the number of replicas, set once the sentences are indexed")
+	elasticsearchSubcommand.String(&refreshInterval, "", "refresh-interval", "the refresh interval, set once the sentences are indexed")
+	elasticsearchSubcommand.String(&codec, "", "codec", "the compression codec, default or best_compression")
+	elasticsearchSubcommand.Bool(&needForceMerge, "", "force-merge", "force merge the index once the sentences are indexed")
+	elasticsearchSubcommand.Bool(&needSuggest, "", "suggest", "add the fields used for the suggestions as you type")
+	elasticsearchSubcommand.String(&usernameElasticsearch, "u", "username", "username for the basic authentication")
+	elasticsearchSubcommand.Bool(&isPasswordRequired, "", "password", "will ask you to enter the password")
+	elasticsearchSubcommand.String(&cloudIDElasticsearch, "", "cloud-id", "the Cloud ID of an Elastic Cloud deployment")
+	elasticsearchSubcommand.String(&caCertElasticsearch, "", "ca-cert", "path of the CA certificate to trust")
+	elasticsearchSubcommand.String(&caFingerprintElasticsearch, "", "ca-fingerprint", "SHA256 fingerprint of the CA certificate to trust")
+	elasticsearchSubcommand.String(&clientCertElasticsearch, "", "cert", "path of the client certificate")
+	elasticsearchSubcommand.String(&clientKeyElasticsearch, "", "key", "path of the client certificate key")
+	elasticsearchSubcommand.Bool(&insecureSkipVerify, "", "insecure-skip-verify", "don't verify the server certificate")
+	elasticsearchSubcommand.String(&deadLetterPath, "", "dead-letter", "file where the failed sentences are written")
+	elasticsearchSubcommand.Int(&maxFailures, "", "max-failures", "the number of failed sentences before exiting with an error")
+
+	// Create the subcommand to rollback the Elasticsearch alias.
+	elasticsearchRollbackSubcommand = flaggy.NewSubcommand("rollback")
+	elasticsearchRollbackSubcommand.Description = "Point the alias back to the previous index."
+	elasticsearchSubcommand.AttachSubcommand(elasticsearchRollbackSubcommand, 1)
+
+	// Create the subcommand to index again the failed sentences.
+	elasticsearchRetryFailedSubcommand = flaggy.NewSubcommand("retry-failed")
+	elasticsearchRetryFailedSubcommand.Description = "Index again the sentences of the dead-letter file."
+	elasticsearchSubcommand.AttachSubcommand(elasticsearchRetryFailedSubcommand, 1)
+
+	// Create the subcommand to index several engines from a single parse.
+	targetsSubcommand = flaggy.NewSubcommand("targets")
+	targetsSubcommand.Description = "Index sentences in all the targets of a file concurrently."
+	targetsSubcommand.String(&targetsPath, "f", "file", "JSON file of the targets, the targets of the configuration file by default")
+
+	// Create the subcommand to save the parsed sentences in a snapshot.
+	buildSubcommand = flaggy.NewSubcommand("build")
+	buildSubcommand.Description = "Parse the files and save the sentences in a snapshot for --from-snapshot."
+	buildSubcommand.String(&snapshotPath, "o", "output", "the snapshot file")
+
+	// Create the subcommand to print the statistics of the export.
+	statsSubcommand = flaggy.NewSubcommand("stats")
+	statsSubcommand.Description = "Print the statistics of the sentences, the audio, the transcriptions and the links."
+	statsSubcommand.String(&statsFormat, "f", "format", "the output format, table or json")
+	statsSubcommand.Int(&statsTop, "", "top", "the number of contributors and language pairs printed")
+
+	// Create the subcommand to search the sentences without search engine.
+	serveSubcommand = flaggy.NewSubcommand("serve")
+	serveSubcommand.Description = "Index the sentences in memory and serve a JSON search API."
+	serveSubcommand.String(&listenAddress, "l", "listen", "the address to listen on")
+
+	// Create the subcommands to check and print the configuration.
+	configSubcommand := flaggy.NewSubcommand("config")
+	configSubcommand.Description = "Check or print the configuration file."
+
+	configValidateSubcommand = flaggy.NewSubcommand("validate")
+	configValidateSubcommand.Description = "Check the configuration file."
+	configSubcommand.AttachSubcommand(configValidateSubcommand, 1)
+
+	configPrintSubcommand = flaggy.NewSubcommand("print")
+	configPrintSubcommand.Description = "Print the configuration with the environment variables and the flags applied."
+	configSubcommand.AttachSubcommand(configPrintSubcommand, 1)
 
 	// Add the subcommands to the parser.
 	flaggy.AttachSubcommand(meiliSearchSubcommand, 1)
 	flaggy.AttachSubcommand(elasticsearchSubcommand, 1)
-
-	// Parse CLI arguments.
-	flaggy.Parse()
-
-	// Check if the number of workers is not exceeded.
-	if numWorkers > runtime.NumCPU() {
-		color.Cyan(fmt.Sprintf("You can't define more than %d workers. The value has been changed with the maximum one.", runtime.NumCPU()))
-		numWorkers = runtime.NumCPU()
-	}
+	flaggy.AttachSubcommand(targetsSubcommand, 1)
+	flaggy.AttachSubcommand(buildSubcommand, 1)
+	flaggy.AttachSubcommand(statsSubcommand, 1)
+	flaggy.AttachSubcommand(serveSubcommand, 1)
+	flaggy.AttachSubcommand(configSubcommand, 1)
 }
 
 // FileExists check if a file exists and returns true if exists, false otherwise.
@@ -84,55 +303,212 @@ func FileExists(path string) bool {
 	return true
 }
 
+// newMeiliSearch create an instance of MeiliSearch from the CLI arguments,
+// printing its messages to the output.
+func newMeiliSearch(output io.Writer) *MeiliSearch {
+	return &MeiliSearch{
+		host:            hostMeiliSearch,
+		indexName:       IndexName,
+		APIKeyRequired:  isAPIKeyRequired,
+		APIKeyFile:      apiKeyFileMeiliSearch,
+		APIKeyCommand:   apiKeyCommandMeiliSearch,
+		settingsPath:    settingsPathMeiliSearch,
+		batchSize:       batchSizeMeiliSearch,
+		maxPendingTasks: maxPendingTasks,
+		compression:     compressionMeiliSearch,
+		output:          output,
+	}
+}
+
+// newElasticsearch create an instance of Elasticsearch from the CLI arguments,
+// printing its messages to the output.
+func newElasticsearch(output io.Writer) *Elasticsearch {
+	return &Elasticsearch{
+		host:                hostElasticsearch,
+		indexName:           IndexName,
+		numWorkers:          numWorkers,
+		flushBytes:          flushBytes,
+		keepIndexes:         keepIndexes,
+		shards:              shards,
+		replicas:            replicas,
+		refreshInterval:     refreshInterval,
+		codec:               codec,
+		forceMergeAfterLoad: needForceMerge,
+		suggest:             needSuggest,
+		username:            usernameElasticsearch,
+		passwordRequired:    isPasswordRequired,
+		cloudID:             cloudIDElasticsearch,
+		caCert:              caCertElasticsearch,
+		caFingerprint:       caFingerprintElasticsearch,
+		clientCert:          clientCertElasticsearch,
+		clientKey:           clientKeyElasticsearch,
+		insecureSkipVerify:  insecureSkipVerify,
+		deadLetterPath:      deadLetterPath,
+		maxFailures:         maxFailures,
+		output:              output,
+	}
+}
+
+// isTerminal check if the standard input is a terminal a secret can be asked on.
+func isTerminal() bool {
+	return term.IsTerminal(int(syscall.Stdin))
+}
+
+// askSecret prompt in terminal to enter a secret without echoing it.
+func askSecret(prompt string) string {
+	// Without terminal, like in a cron job or a container,
+	// the secret can't be asked.
+	if !isTerminal() {
+		color.Red("Cannot ask a secret without a terminal, use an environment variable instead.")
+		os.Exit(1)
+	}
+
+	fmt.Print(prompt)
+
+	// Read the secret from the terminal.
+	secret, err := term.ReadPassword(int(syscall.Stdin))
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println()
+
+	return string(secret)
+}
+
 func main() {
 	// Parse CLI arguments.
 	parseCLIArguments()
 
 	// If no subcommand was specified, show help and exit.
-	if len(os.Args) == 1 {
+	if engineName == "" && !targetsSubcommand.Used && !buildSubcommand.Used && !statsSubcommand.Used && !serveSubcommand.Used &&
+		!configValidateSubcommand.Used && !configPrintSubcommand.Used {
 		flaggy.ShowHelpAndExit("")
 	}
 
-	// Download files if needed.
-	if needDownloadFiles ||
+	// Check or print the configuration without indexing anything.
+	if configValidateSubcommand.Used {
+		ValidateConfig()
+		return
+	}
+
+	if configPrintSubcommand.Used {
+		PrintConfig()
+		return
+	}
+
+	// Rollback the Elasticsearch alias without indexing anything.
+	if elasticsearchRollbackSubcommand.Used {
+		client := newElasticsearch(os.Stdout)
+
+		if err := client.Connect(); err != nil {
+			log.Fatal(err)
+		}
+
+		if err := client.Rollback(); err != nil {
+			log.Fatalf("Cannot rollback the alias: %s", err)
+		}
+
+		return
+	}
+
+	// Index again the failed sentences without parsing the files.
+	if elasticsearchRetryFailedSubcommand.Used {
+		client := newElasticsearch(os.Stdout)
+
+		if err := client.Connect(); err != nil {
+			log.Fatal(err)
+		}
+
+		if err := client.RetryFailed(); err != nil {
+			log.Fatalf("Cannot index the failed sentences: %s", err)
+		}
+
+		return
+	}
+
+	// Download files if needed, the sentences of a snapshot
+	// have already been parsed.
+	if fromSnapshotPath == "" && (needDownloadFiles ||
 		!FileExists(os.TempDir()+SentencesDetailed+".csv") ||
 		!FileExists(os.TempDir()+Links+".csv") ||
-		!FileExists(os.TempDir()+SentencesWithAudio+".csv") {
+		!FileExists(os.TempDir()+SentencesWithAudio+".csv")) {
 		DownloadFiles(needDownloadFiles)
 	}
 
-	// Declare the client.
-	var client Indexer
+	// Print the statistics without indexing the sentences.
+	if statsSubcommand.Used {
+		PrintStats(ComputeStats(statsTop), statsFormat, statsTop)
+		return
+	}
 
-	// Create the client depending of the user choice.
-	switch os.Args[1] {
-	case meilisearchName:
-		// Create an instance of MeiliSearch.
-		client = &MeiliSearch{
-			host:           hostMeiliSearch,
-			APIKeyRequired: isAPIKeyRequired,
-		}
-	case elasticsearchName:
-		// Create an instance of Elasticsearch.
-		client = &Elasticsearch{
-			host:       hostElasticsearch,
-			numWorkers: numWorkers,
-			flushBytes: flushBytes,
+	// Search the sentences indexed in memory.
+	if serveSubcommand.Used {
+		if fromSnapshotPath != "" {
+			Serve(loadSnapshot(fromSnapshotPath), listenAddress)
+		} else {
+			Serve(parseFiles(), listenAddress)
 		}
+
+		return
 	}
 
-	// Init the MeiliSearch client.
-	client.Init()
+	// Save the parsed sentences without indexing them.
+	if buildSubcommand.Used {
+		buildSnapshot()
+		return
+	}
 
+	// Parse the files once and index the sentences in every target.
+	if targetsSubcommand.Used {
+		indexTargets()
+		return
+	}
+
+	// Index the sentences in the engine.
+	run := newIndexRun(engineName, engineName, os.Stdout)
+
+	err := run.index(func() map[string]Sentence {
+		// Continue with the sentences parsed by the interrupted run.
+		if run.resume {
+			return loadSnapshot(run.checkpoint.SnapshotPath())
+		}
+
+		if fromSnapshotPath != "" {
+			return loadSnapshot(fromSnapshotPath)
+		}
+
+		return parseFiles()
+	})
+
+	if err != nil {
+		color.Red("Cannot index the sentences: %s.", err)
+		os.Exit(1)
+	}
+}
+
+// parseFiles parse the downloaded files and returns the sentences
+// with their audio, relations and transcriptions.
+func parseFiles() map[string]Sentence {
 	// Parse the sentences.
 	fmt.Print("Parsing sentences...")
-	sentences := ParseSentences()
+	sentences, _ := ParseSentences()
 	color.Green(fmt.Sprintf("%c[2K\rSentences has been parsed", 27))
 
+	// Keep the sentences of the given languages, before adding the relations
+	// so the sentences are only linked to the indexed ones.
+	if languagesFilter != "" {
+		tatoeba.FilterLanguages(sentences, strings.Split(languagesFilter, ","))
+		color.Green("%d sentences in %s have been kept", len(sentences), languagesFilter)
+	}
+
 	// Parse the audio file and update the sentences map.
-	fmt.Print("Flag sentences with audio...")
-	ParseSentencesWithAudio(&sentences)
-	color.Green(fmt.Sprintf("%c[2K\rSentences with audio has been flagged", 27))
+	if !skipAudio {
+		fmt.Print("Flag sentences with audio...")
+		ParseSentencesWithAudio(&sentences)
+		color.Green(fmt.Sprintf("%c[2K\rSentences with audio has been flagged", 27))
+	}
 
 	// Parse the links between sentences and update the sentences map.
 	fmt.Print("Add direct relations between sentences...")
@@ -140,17 +516,109 @@ func main() {
 	color.Green(fmt.Sprintf("%c[2K\rDirect relations has been added", 27))
 
 	// Add indirect relations between sentences.
-	fmt.Print("Add indirect relations between sentences...")
-	FindIndirectRelations(&sentences)
-	color.Green(fmt.Sprintf("%c[2K\rIndirect relations has been added", 27))
+	if !skipIndirectRelations {
+		fmt.Print("Add indirect relations between sentences...")
+		FindIndirectRelations(&sentences)
+		color.Green(fmt.Sprintf("%c[2K\rIndirect relations has been added", 27))
+	}
 
 	// Add some languages transcriptions.
-	fmt.Print("Add transcriptions...")
-	ParseTranscriptions(&sentences)
-	color.Green(fmt.Sprintf("%c[2K\rTranscriptions has been added", 27))
+	if !skipTranscriptions {
+		fmt.Print("Add transcriptions...")
+		ParseTranscriptions(&sentences)
+		color.Green(fmt.Sprintf("%c[2K\rTranscriptions has been added", 27))
+	}
+
+	return sentences
+}
 
-	// Index sentences.
-	client.Index(sentences)
+// parseOptions describes the options changing the parsed sentences,
+// a snapshot is only reused when they are the same.
+func parseOptions() string {
+	return fmt.Sprintf("languages=%s skip-audio=%t skip-indirect-relations=%t skip-transcriptions=%t",
+		languagesFilter, skipAudio, skipIndirectRelations, skipTranscriptions)
+}
 
-	fmt.Println()
+// loadSnapshot load the sentences parsed by a previous run.
+func loadSnapshot(path string) map[string]Sentence {
+	fmt.Print("Loading the parsed sentences...")
+	header, sentences := ReadSnapshot(path)
+	color.Green("%c[2K\r%d sentences parsed on %s have been loaded", 27, len(sentences), header.CreatedAt.Format("2006-01-02 15:04"))
+
+	return sentences
+}
+
+// sentencesManifest returns the manifest of the files
+// the indexed sentences have been parsed from.
+func sentencesManifest() ([]ManifestFile, error) {
+	if fromSnapshotPath != "" {
+		header, err := ReadSnapshotHeader(fromSnapshotPath)
+
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the snapshot \"%s\": %s", fromSnapshotPath, err)
+		}
+
+		return header.Manifest, nil
+	}
+
+	return InputManifest()
+}
+
+// buildSnapshot parse the files and save the sentences in a snapshot,
+// unless the snapshot has already been built from the same files.
+func buildSnapshot() {
+	manifest, err := InputManifest()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	header, err := ReadSnapshotHeader(snapshotPath)
+
+	if err == nil && header.Fingerprint == ManifestFingerprint(manifest) && header.ParseOptions == parseOptions() {
+		color.Green("The snapshot \"%s\" is up to date with the files, %d sentences.", snapshotPath, header.Total)
+		return
+	}
+
+	sentences := parseFiles()
+
+	fmt.Print("Saving the parsed sentences...")
+
+	if err := WriteSnapshot(snapshotPath, manifest, sentences); err != nil {
+		log.Fatal(err)
+	}
+
+	color.Green("%c[2K\r%d sentences have been saved to \"%s\"", 27, len(sentences), snapshotPath)
+}
+
+// indexTargets parse the files and index the sentences in all the targets
+// of the targets file, then exit with an error if a target failed.
+func indexTargets() {
+	var targets []Target
+
+	if targetsPath != "" {
+		targets = ReadTargets(targetsPath)
+	} else if loadedConfig != nil {
+		targets = loadedConfig.SelectedTargets()
+	}
+
+	if len(targets) == 0 {
+		color.Red("There is no target to index, give a targets file with -f or a configuration file.")
+		os.Exit(1)
+	}
+
+	var sentences map[string]Sentence
+
+	if fromSnapshotPath != "" {
+		sentences = loadSnapshot(fromSnapshotPath)
+	} else {
+		sentences = parseFiles()
+	}
+
+	color.Green("Indexing in %d targets", len(targets))
+
+	// The targets share the parsed sentences.
+	if !IndexTargets(targets, sentences) {
+		os.Exit(1)
+	}
 }
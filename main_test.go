@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	json2 "encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fixturePath is the directory of the fixture export, shared with the tatoeba package.
+var fixturePath = filepath.Join("tatoeba", "testdata")
+
+// fixtureSentences parse the fixture export like the command does,
+// the files are read from the temporary directory.
+func fixtureSentences(t *testing.T) map[string]Sentence {
+	t.Helper()
+
+	exportPath, err := filepath.Abs(filepath.Join(fixturePath, "export"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The files are looked for in the temporary directory.
+	previous, set := os.LookupEnv("TMPDIR")
+	os.Setenv("TMPDIR", exportPath+string(filepath.Separator))
+
+	defer func() {
+		if set {
+			os.Setenv("TMPDIR", previous)
+		} else {
+			os.Unsetenv("TMPDIR")
+		}
+	}()
+
+	return parseFiles()
+}
+
+// encodeSentence returns the JSON of the sentence, as sent to the engines.
+func encodeSentence(t *testing.T, sentence Sentence) string {
+	t.Helper()
+
+	sentenceAsJSON, err := json2.Marshal(sentence)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(sentenceAsJSON)
+}
+
+func TestParseFilesGolden(t *testing.T) {
+	actual, err := json2.MarshalIndent(fixtureSentences(t), "", "  ")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := ioutil.ReadFile(filepath.Join(fixturePath, "sentences.golden.json"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(append(actual, '\n'), expected) {
+		t.Errorf("The parsed sentences differ from the golden file:\n%s", actual)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	json2 "encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+
+	"github.com/meilisearch/meilisearch-go"
+)
+
+// unorderedMeiliSearchSettings are the settings returned sorted by
+// MeiliSearch, their order doesn't matter when verifying them.
+var unorderedMeiliSearchSettings = map[string]bool{
+	"filterableAttributes": true,
+	"sortableAttributes":   true,
+	"stopWords":            true,
+}
+
+// defaultMeiliSearchSettings returns the settings used when
+// no settings file is given.
+func defaultMeiliSearchSettings() *meilisearch.Settings {
+	return &meilisearch.Settings{
+		SearchableAttributes: []string{"content", "transcriptions.transcription", "id", "username"},
+		FilterableAttributes: []string{"language", "translated_languages", "username", "audio_username", "direct_translations", "indirect_translations"},
+		SortableAttributes:   []string{"id", "added_at", "updated_at"},
+	}
+}
+
+// readMeiliSearchSettings read the settings from a JSON file using
+// the same format as the settings route of MeiliSearch.
+func readMeiliSearchSettings(path string) (*meilisearch.Settings, error) {
+	if path == "" {
+		return defaultMeiliSearchSettings(), nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the settings file: %s", err)
+	}
+
+	var settings meilisearch.Settings
+
+	if err := json2.Unmarshal(content, &settings); err != nil {
+		return nil, fmt.Errorf("cannot decode the settings file: %s", err)
+	}
+
+	return &settings, nil
+}
+
+// applySettings update the settings of the index and verify
+// MeiliSearch applied them.
+func (m MeiliSearch) applySettings(uid string) error {
+	index := m.client.Index(uid)
+
+	if _, err := m.waitForTask(index.UpdateSettings(m.settings)); err != nil {
+		return err
+	}
+
+	// Get the settings really used by the index.
+	applied, err := index.GetSettings()
+
+	if err != nil {
+		return err
+	}
+
+	// Compare the settings as JSON, only the ones given are verified.
+	var expected, actual map[string]interface{}
+
+	if err := convertToMap(m.settings, &expected); err != nil {
+		return err
+	}
+
+	if err := convertToMap(applied, &actual); err != nil {
+		return err
+	}
+
+	for name, value := range expected {
+		if !settingsEqual(name, value, actual[name]) {
+			return fmt.Errorf("the setting \"%s\" has not been applied: expected %v, got %v", name, value, actual[name])
+		}
+	}
+
+	return nil
+}
+
+// convertToMap convert a struct into a map using its JSON representation.
+func convertToMap(value interface{}, converted *map[string]interface{}) error {
+	content, err := json2.Marshal(value)
+
+	if err != nil {
+		return err
+	}
+
+	return json2.Unmarshal(content, converted)
+}
+
+// settingsEqual check if the expected setting is the same as the actual one.
+// Only the keys of the expected objects are compared, MeiliSearch returns
+// the default values of the missing ones.
+func settingsEqual(name string, expected, actual interface{}) bool {
+	switch expectedValue := expected.(type) {
+	case nil:
+		// The setting hasn't been given.
+		return true
+	case map[string]interface{}:
+		actualValue, ok := actual.(map[string]interface{})
+
+		if !ok {
+			return false
+		}
+
+		for key, value := range expectedValue {
+			if !settingsEqual(key, value, actualValue[key]) {
+				return false
+			}
+		}
+
+		return true
+	case []interface{}:
+		actualValue, ok := actual.([]interface{})
+
+		if !ok || len(expectedValue) != len(actualValue) {
+			return false
+		}
+
+		// Sort the lists MeiliSearch doesn't keep in order.
+		if unorderedMeiliSearchSettings[name] {
+			return reflect.DeepEqual(sortedStrings(expectedValue), sortedStrings(actualValue))
+		}
+
+		return reflect.DeepEqual(expectedValue, actualValue)
+	default:
+		return reflect.DeepEqual(expected, actual)
+	}
+}
+
+// sortedStrings returns the sorted string representation of values.
+func sortedStrings(values []interface{}) []string {
+	sorted := make([]string, len(values))
+
+	for i, value := range values {
+		sorted[i] = fmt.Sprint(value)
+	}
+
+	sort.Strings(sorted)
+
+	return sorted
+}
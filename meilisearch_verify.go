@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/meilisearch/meilisearch-go"
+)
+
+// CountDocuments returns the number of documents in the live index.
+func (m MeiliSearch) CountDocuments() (int, error) {
+	stats, err := m.client.Index(m.indexName).GetStats()
+
+	if err != nil {
+		return 0, err
+	}
+
+	return int(stats.NumberOfDocuments), nil
+}
+
+// DocumentIDs returns the IDs of all the documents in the live index.
+func (m MeiliSearch) DocumentIDs() (map[string]bool, error) {
+	IDs := make(map[string]bool)
+	index := m.client.Index(m.indexName)
+
+	for offset := int64(0); ; offset += int64(m.batchSize) {
+		var documents meilisearch.DocumentsResult
+
+		// Get the IDs only of the next page.
+		err := index.GetDocuments(&meilisearch.DocumentsQuery{
+			Offset: offset,
+			Limit:  int64(m.batchSize),
+			Fields: []string{"id"},
+		}, &documents)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, document := range documents.Results {
+			if ID, ok := document["id"].(float64); ok {
+				IDs[strconv.Itoa(int(ID))] = true
+			}
+		}
+
+		if len(documents.Results) < m.batchSize {
+			return IDs, nil
+		}
+	}
+}
+
+// FailureReasons returns no reason, a failed task stops the indexation.
+func (m MeiliSearch) FailureReasons() (map[string]string, error) {
+	return make(map[string]string), nil
+}
+
+// Push index the given sentences in the live index, a failed
+// task stops the indexation so all the sentences have been indexed.
+func (m MeiliSearch) Push(sentences map[string]Sentence) ([]string, error) {
+	index := m.client.Index(m.indexName)
+
+	var batch []byte
+
+	for _, sentence := range sentences {
+		batch = append(sentence.AppendJSON(batch), '\n')
+	}
+
+	taskInfos, err := index.AddDocumentsNdjsonInBatches(batch, m.batchSize, "id")
+
+	for i := range taskInfos {
+		if _, err := m.waitForTask(&taskInfos[i], nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, err
+}
+
+// Delete remove the given sentences from the live index, a failed
+// task stops the deletion so all the sentences have been deleted.
+func (m MeiliSearch) Delete(IDs []string) ([]string, error) {
+	index := m.client.Index(m.indexName)
+
+	for start := 0; start < len(IDs); start += m.batchSize {
+		end := start + m.batchSize
+
+		if end > len(IDs) {
+			end = len(IDs)
+		}
+
+		if _, err := m.waitForTask(index.DeleteDocuments(IDs[start:end])); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// printColor print the line in the color to the output,
+// like color.Green prints it on the standard output.
+func printColor(output io.Writer, attribute color.Attribute, format string, a ...interface{}) {
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
+	}
+
+	color.New(attribute).Fprintf(output, format, a...)
+}
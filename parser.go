@@ -1,17 +1,16 @@
 package main
 
 import (
-	"bufio"
 	"log"
 	"os"
-	"strconv"
-	"strings"
+
+	"tatoeba-indexer/tatoeba"
 
 	"github.com/fatih/color"
 )
 
-// readCSV read Tatoeba's CSV and returns a reader.
-func readCSV(filename string) *bufio.Scanner {
+// openCSV open one of Tatoeba's CSV downloaded in the temporary directory.
+func openCSV(filename string) *os.File {
 	// Create the filepath.
 	filepath := os.TempDir() + filename
 
@@ -21,224 +20,87 @@ func readCSV(filename string) *bufio.Scanner {
 		os.Exit(0)
 	}
 
-	// Open the sentences file.
+	// Open the file.
 	file, err := os.Open(filepath)
 
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	return bufio.NewScanner(file)
+	return file
 }
 
-// ParseSentences will parse the file `sentences_detailed.csv`
-// and returns a map of `Sentence`.
-func ParseSentences() map[string]Sentence {
-	// Open the sentences file.
-	scanner := readCSV(SentencesDetailed + ".csv")
-
-	// Scan lines.
-	scanner.Split(bufio.ScanLines)
-
-	// Create an empty map of sentences.
-	sentences := make(map[string]Sentence)
-
-	// Loop over all lines and create a struct of Sentence.
-	for scanner.Scan() {
-		// Read and split the current line.
-		line := strings.Split(scanner.Text(), "\t")
-
-		// If the language code is not 3 characters,
-		// ignore the line.
-		if len(line[1]) < 3 {
-			continue
-		}
-
-		// Convert the id from a string to an int.
-		id, err := strconv.Atoi(line[0])
-
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Convert the added date to nil if the csv value
-		// is equal to \N.
-		addedAt := line[4]
-
-		if addedAt == "\\N" || addedAt == "0000-00-00 00:00:00" {
-			addedAt = ""
-		}
+// parseCSV open the file and parse it with the given function,
+// the program stops if the file is malformed.
+func parseCSV(filename string, parse func(file *os.File) error) {
+	file := openCSV(filename)
+	defer file.Close()
 
-		// Do the same as before for the updated date.
-		updatedAt := line[5]
+	if err := parse(file); err != nil {
+		log.Fatalf("Cannot parse \"%s\": %s", filename, err)
+	}
+}
 
-		if updatedAt == "\\N" || updatedAt == "0000-00-00 00:00:00" {
-			updatedAt = ""
-		}
+// ParseSentences will parse the file `sentences_detailed.csv` and returns a map
+// of `Sentence`, with the number of sentences skipped for their unknown language.
+func ParseSentences() (map[string]Sentence, int) {
+	var sentences map[string]Sentence
+	var skipped int
 
-		// Create a sentence and append it to the
-		// slice to returns.
-		sentences[line[0]] = Sentence{
-			ID:                  int32(id),
-			Language:            line[1],
-			Content:             line[2],
-			Username:            line[3],
-			AddedAt:             addedAt,
-			UpdatedAt:           updatedAt,
-			DirectRelations:     make([]int32, 0),
-			IndirectRelations:   make([]int32, 0),
-			TranslatedLanguages: make([]string, 0),
-			AudioUsername:       "",
-			Transcriptions:      make([]Transcription, 0),
-		}
-	}
+	parseCSV(SentencesDetailed+".csv", func(file *os.File) (err error) {
+		sentences, skipped, err = tatoeba.ReadSentences(file)
+		return err
+	})
 
-	return sentences
+	return sentences, skipped
 }
 
 // ParseSentencesLink will parse the file `links.csv`
-// and add direct translations between sentences.
-func ParseSentencesLink(sentences *map[string]Sentence) {
-	// Open the links file.
-	scanner := readCSV(Links + ".csv")
-
-	// Scan lines.
-	scanner.Split(bufio.ScanLines)
-
-	// Loop over all lines and create a struct of SentencesLink.
-	for scanner.Scan() {
-		// Read the current line.
-		line := strings.Split(scanner.Text(), "\t")
-
-		// Get the sentences from the map.
-		fromSentence, fromIDExist := (*sentences)[line[0]]
-		toSentence, toIDExist := (*sentences)[line[1]]
-
-		// Insert the relation if both ids exists in the sentences map.
-		if fromIDExist && toIDExist {
-			// Add the relation.
-			fromSentence.DirectRelations = append(fromSentence.DirectRelations, toSentence.ID)
-
-			// Add the translated language if not present in the array.
-			languageExists := languageExists(toSentence.Language, fromSentence.TranslatedLanguages)
-
-			// Add the language if not exist.
-			if !languageExists {
-				fromSentence.TranslatedLanguages = append(fromSentence.TranslatedLanguages, toSentence.Language)
-			}
-
-			// Update sentence.
-			(*sentences)[line[0]] = fromSentence
-		}
-	}
+// and add direct translations between sentences. It returns
+// the number of links referencing a missing sentence.
+func ParseSentencesLink(sentences *map[string]Sentence) int {
+	var ignored int
+
+	parseCSV(Links+".csv", func(file *os.File) (err error) {
+		ignored, err = tatoeba.AddLinks(*sentences, file)
+		return err
+	})
+
+	return ignored
 }
 
 // FindIndirectRelations add indirect translations between sentences.
 func FindIndirectRelations(sentences *map[string]Sentence) {
-	// Loop over all sentences.
-	for ID, sentence := range *sentences {
-		// If the sentence haven't direct relation, skip here.
-		if len(sentence.DirectRelations) == 0 {
-			continue
-		}
-
-		// Loop over all direct relations.
-		for _, directSentenceID := range sentence.DirectRelations {
-			// Get the sentence from the ID.
-			directSentence := (*sentences)[strconv.Itoa(int(directSentenceID))]
-
-			// Loop over all the direct relation sentence direct relations.
-		DirectRelationLoop:
-			for _, directDirectSentenceID := range directSentence.DirectRelations {
-				// Continue to the next sentence if the direc direct sentence ID
-				// is the same as the sentence ID.
-				if directDirectSentenceID == sentence.ID {
-					continue
-				}
-
-				// Check if the direct direct sentence is already
-				// inside the direct and indirect relations
-				for _, directRelationSentenceID := range sentence.DirectRelations {
-					if directDirectSentenceID == directRelationSentenceID {
-						continue DirectRelationLoop
-					}
-				}
-
-				for _, indirectRelationSentenceID := range sentence.IndirectRelations {
-					if directDirectSentenceID == indirectRelationSentenceID {
-						continue DirectRelationLoop
-					}
-				}
-
-				// Add the direct direct relation to the indirect relation.
-				sentence.IndirectRelations = append(sentence.IndirectRelations, directDirectSentenceID)
-
-				// Get the direct direct sentence.
-				directDirectSentence := (*sentences)[strconv.Itoa(int(directDirectSentenceID))]
-
-				// Add the language in translated languages if haven't.
-				languageExists := languageExists(directDirectSentence.Language, sentence.TranslatedLanguages)
-
-				// Add the language if not founded.
-				if !languageExists {
-					sentence.TranslatedLanguages = append(sentence.TranslatedLanguages, directDirectSentence.Language)
-				}
-			}
-		}
-
-		// Update sentence.
-		(*sentences)[ID] = sentence
-	}
+	tatoeba.AddIndirectRelations(*sentences)
 }
 
 // ParseSentencesWithAudio will parse the file `sentences_with_audio.csv`
 // and update the list of `Sentence` setting the `AudioUsername` property with
 // the audio recorder username if the sentence id has been found in this file.
-func ParseSentencesWithAudio(sentences *map[string]Sentence) {
-	// Open the links file.
-	scanner := readCSV(SentencesWithAudio + ".csv")
-
-	// Scan lines.
-	scanner.Split(bufio.ScanLines)
-
-	// Loop over all lines and create a struct of SentencesLink.
-	for scanner.Scan() {
-		// Read the current line.
-		line := strings.Split(scanner.Text(), "\t")
-
-		// Update the audio username in the sentences map.
-		var sentence = (*sentences)[line[0]]
-		sentence.AudioUsername = line[1]
-		(*sentences)[line[0]] = sentence
-	}
+// It returns the number of audio of a missing sentence.
+func ParseSentencesWithAudio(sentences *map[string]Sentence) int {
+	var ignored int
+
+	parseCSV(SentencesWithAudio+".csv", func(file *os.File) (err error) {
+		ignored, err = tatoeba.AddAudio(*sentences, file)
+		return err
+	})
+
+	return ignored
 }
 
 // ParseTranscriptions will parse the file `transcritions.csv`
-// and add transcriptions to the sentences.
-func ParseTranscriptions(sentences *map[string]Sentence) {
-	// Open the links file.
-	scanner := readCSV(Transcriptions + ".csv")
-
-	// Scan lines.
-	scanner.Split(bufio.ScanLines)
-
-	// Loop over all lines and add transcriptions.
-	for scanner.Scan() {
-		// Read the current line.
-		line := strings.Split(scanner.Text(), "\t")
-
-		// Create the transcription struct
-		transcription := Transcription{
-			ScriptName:    line[2],
-			Username:      line[3],
-			Transcription: line[4],
-		}
+// and add transcriptions to the sentences. It returns the
+// number of transcriptions of a missing sentence.
+func ParseTranscriptions(sentences *map[string]Sentence) int {
+	var ignored int
 
-		// Add the transcription to the sentence.
-		var sentence = (*sentences)[line[0]]
-		sentence.Transcriptions = append(sentence.Transcriptions, transcription)
-		(*sentences)[line[0]] = sentence
-	}
+	parseCSV(Transcriptions+".csv", func(file *os.File) (err error) {
+		ignored, err = tatoeba.AddTranscriptions(*sentences, file)
+		return err
+	})
+
+	return ignored
 }
 
 // languageExists check if the given language exists in the array of languages.
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fatih/color"
+)
+
+// DeleteSentences delete the given sentences from the live index, refusing
+// to delete more than maxPercent of the documents without force.
+// It returns the IDs of the sentences the engine didn't delete.
+func DeleteSentences(output io.Writer, indexer Indexer, IDs []string, maxPercent float64, force bool) ([]string, error) {
+	if len(IDs) == 0 {
+		return nil, nil
+	}
+
+	// A broken export would delete most of the index,
+	// stop before it happens.
+	total, err := indexer.CountDocuments()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !force && float64(len(IDs)) > float64(total)*maxPercent/100 {
+		return nil, fmt.Errorf("refusing to delete %d of %d documents, more than %.1f%%, use --force to delete them anyway", len(IDs), total, maxPercent)
+	}
+
+	fmt.Fprintf(output, "Deleting %d sentences removed from Tatoeba...\n", len(IDs))
+	failed, err := indexer.Delete(IDs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(failed) > 0 {
+		printColor(output, color.FgRed, "%d sentences could not be deleted.", len(failed))
+	}
+
+	return failed, nil
+}
+
+// Prune delete the documents of the live index which are not
+// in the parsed sentences anymore, within the limit of DeleteSentences.
+func Prune(output io.Writer, indexer Indexer, sentences map[string]Sentence, maxPercent float64, force bool) error {
+	fmt.Fprint(output, "Looking for sentences removed from Tatoeba...")
+
+	IDs, err := indexer.DocumentIDs()
+
+	if err != nil {
+		return err
+	}
+
+	var deleted []string
+
+	for ID := range IDs {
+		if _, exists := sentences[ID]; !exists {
+			deleted = append(deleted, ID)
+		}
+	}
+
+	fmt.Fprintf(output, "%c[2K\r%d sentences have been removed from Tatoeba.\n", 27, len(deleted))
+
+	_, err = DeleteSentences(output, indexer, deleted, maxPercent, force)
+
+	return err
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/fatih/color"
+)
+
+// Reconcile compare the parsed sentences with the documents in the engine,
+// print the missing and extra ones and optionally index the missing ones again.
+func Reconcile(output io.Writer, indexer Indexer, sentences map[string]Sentence, repair bool) error {
+	fmt.Fprint(output, "Verifying the indexed sentences...")
+
+	// Compare the counts first, it's far cheaper than listing the IDs.
+	count, err := indexer.CountDocuments()
+
+	if err != nil {
+		return err
+	}
+
+	if count == len(sentences) {
+		printColor(output, color.FgGreen, "%c[2K\rThe %d sentences are indexed", 27, count)
+		return nil
+	}
+
+	// List the IDs to find the differences.
+	IDs, err := indexer.DocumentIDs()
+
+	if err != nil {
+		return err
+	}
+
+	reasons, err := indexer.FailureReasons()
+
+	if err != nil {
+		return err
+	}
+
+	missing := make(map[string]Sentence)
+
+	for ID, sentence := range sentences {
+		if !IDs[ID] {
+			missing[ID] = sentence
+		}
+	}
+
+	var extra []string
+
+	for ID := range IDs {
+		if _, exists := sentences[ID]; !exists {
+			extra = append(extra, ID)
+		}
+	}
+
+	printColor(output, color.FgYellow, "%c[2K\rThe engine contains %d documents for %d sentences", 27, count, len(sentences))
+
+	// Print the missing sentences with the reason.
+	for _, ID := range SortedIDs(missing) {
+		reason, rejected := reasons[ID]
+
+		if !rejected {
+			reason = invalidFields(missing[ID])
+		}
+
+		fmt.Fprintf(output, "Missing sentence %s: %s\n", ID, reason)
+	}
+
+	sort.Strings(extra)
+
+	for _, ID := range extra {
+		fmt.Fprintf(output, "Extra document %s: not in the parsed sentences\n", ID)
+	}
+
+	fmt.Fprintf(output, "%d missing and %d extra documents.\n", len(missing), len(extra))
+
+	// Index the missing sentences again if asked.
+	if repair && len(missing) > 0 {
+		fmt.Fprintf(output, "Indexing again %d missing sentences...\n", len(missing))
+
+		if _, err := indexer.Push(missing); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// invalidFields returns the fields of the sentence an engine
+// could reject, or an unknown reason if the sentence looks valid.
+func invalidFields(sentence Sentence) string {
+	switch {
+	case sentence.ID <= 0:
+		return "invalid field id"
+	case sentence.Content == "":
+		return "invalid field content: empty"
+	case len(sentence.Language) < 3:
+		return "invalid field language"
+	}
+
+	return "unknown reason, not rejected by the engine"
+}
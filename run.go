@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// indexRun describes a run indexing the sentences in an engine,
+// with the options given by the flags or by a target.
+type indexRun struct {
+	engine string
+	client Indexer
+
+	// Files keeping the progress of the run and the hashes
+	// of the sentences indexed for the next delta run.
+	checkpointPath, hashesPath string
+	checkpoint                 *Checkpoint
+
+	verify, repair, delta  bool
+	needCheckpoint, resume bool
+	prune, force           bool
+	maxDeletePercent       float64
+
+	// Output of the messages and of the progress.
+	output io.Writer
+}
+
+// newIndexRun create the run of the engine from the CLI arguments, the
+// state files are named after the index and the given name by default.
+func newIndexRun(engine, name string, output io.Writer) *indexRun {
+	run := &indexRun{
+		engine:           engine,
+		checkpointPath:   checkpointPath,
+		hashesPath:       hashesPath,
+		verify:           needVerify,
+		repair:           needRepair,
+		delta:            needDelta,
+		needCheckpoint:   needCheckpoint,
+		resume:           needResume,
+		prune:            needPrune,
+		force:            needForce,
+		maxDeletePercent: maxDeletePercent,
+		output:           output,
+	}
+
+	if run.checkpointPath == "" {
+		run.checkpointPath = fmt.Sprintf("%s.%s.checkpoint", IndexName, name)
+	}
+
+	if run.hashesPath == "" {
+		run.hashesPath = fmt.Sprintf("%s.%s.hashes", IndexName, name)
+	}
+
+	// Create the client depending of the user choice.
+	switch engine {
+	case meilisearchName:
+		run.client = newMeiliSearch(output)
+	case elasticsearchName:
+		run.client = newElasticsearch(output)
+	}
+
+	return run
+}
+
+// index the sentences returned by load once the engine is ready,
+// then prune and verify the index if asked. It returns the error
+// which stopped the run.
+func (r *indexRun) index(load func() map[string]Sentence) error {
+	// A delta run updates the live index, there is no build to resume.
+	if r.resume && r.delta {
+		return errors.New("the --resume and --delta options cannot be used together")
+	}
+
+	// Read the checkpoint of the interrupted run, or create
+	// a new one if the progress needs to be saved.
+	if r.resume || r.needCheckpoint {
+		manifest, err := sentencesManifest()
+
+		if err != nil {
+			return err
+		}
+
+		if r.resume {
+			if r.checkpoint, err = ReadCheckpoint(r.checkpointPath); err != nil {
+				return err
+			}
+
+			if r.checkpoint.Engine != r.engine {
+				return fmt.Errorf("the checkpoint is for %s, not %s", r.checkpoint.Engine, r.engine)
+			}
+
+			// The sentences must be the same as the ones already indexed.
+			if r.checkpoint.Fingerprint != ManifestFingerprint(manifest) {
+				return errors.New("the files changed since the interrupted run, it cannot be resumed")
+			}
+		} else {
+			r.checkpoint = NewCheckpoint(r.checkpointPath, r.engine, ManifestFingerprint(manifest))
+		}
+	}
+
+	// The client saves its progress in the checkpoint.
+	switch client := r.client.(type) {
+	case *MeiliSearch:
+		client.checkpoint = r.checkpoint
+	case *Elasticsearch:
+		client.checkpoint = r.checkpoint
+	}
+
+	// Read the hashes of the sentences indexed by the last run.
+	previousHashes, hasPreviousRun, err := ReadSentenceHashes(r.hashesPath)
+
+	if err != nil {
+		return err
+	}
+
+	// Index only the changes in the live index if the last run
+	// is known, otherwise build a whole new index.
+	isDelta := r.delta && hasPreviousRun
+
+	// Continue the build of the interrupted run, or
+	// connect to the engine before loading the sentences.
+	switch {
+	case r.resume:
+		err = r.client.Resume()
+	case isDelta:
+		err = r.client.Connect()
+	default:
+		err = r.client.Init()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	sentences := load()
+
+	// Save the parsed sentences to resume the run without parsing again.
+	if r.checkpoint != nil && !r.resume && !isDelta {
+		manifest, err := sentencesManifest()
+
+		if err != nil {
+			return err
+		}
+
+		if err := WriteSnapshot(r.checkpoint.SnapshotPath(), manifest, sentences); err != nil {
+			return err
+		}
+	}
+
+	// Index sentences.
+	if isDelta {
+		// Find the sentences changed since the last run.
+		hashes := HashSentences(sentences)
+		changed, deleted := Delta(previousHashes, hashes, sentences)
+
+		fmt.Fprintf(r.output, "%d sentences added or changed and %d deleted since the last run.\n", len(changed), len(deleted))
+
+		var failed []string
+
+		if len(changed) > 0 {
+			if failed, err = r.client.Push(changed); err != nil {
+				return err
+			}
+		}
+
+		notDeleted, err := DeleteSentences(r.output, r.client, deleted, r.maxDeletePercent, r.force)
+
+		if err != nil {
+			return err
+		}
+
+		// Only save the changes the engine applied.
+		hashes.Restore(previousHashes, append(failed, notDeleted...))
+
+		if err := hashes.Write(r.hashesPath); err != nil {
+			return err
+		}
+	} else {
+		if err := r.client.Index(sentences); err != nil {
+			return err
+		}
+
+		// The run succeeded, there is nothing to resume.
+		r.checkpoint.Remove()
+
+		// Save the hashes for the next delta run, without
+		// the sentences rejected by the engine.
+		if r.delta {
+			hashes := HashSentences(sentences)
+			reasons, err := r.client.FailureReasons()
+
+			if err != nil {
+				return err
+			}
+
+			var failed []string
+
+			for ID := range reasons {
+				failed = append(failed, ID)
+			}
+
+			hashes.Restore(nil, failed)
+
+			if err := hashes.Write(r.hashesPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Delete the documents of the sentences removed from Tatoeba.
+	if r.prune {
+		if err := Prune(r.output, r.client, sentences, r.maxDeletePercent, r.force); err != nil {
+			return err
+		}
+	}
+
+	// Compare the indexed sentences with the parsed ones.
+	if r.verify || r.repair {
+		fmt.Fprintln(r.output)
+
+		if err := Reconcile(r.output, r.client, sentences, r.repair); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(r.output)
+
+	return nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SearchIndex is an in-memory full-text index of the sentences,
+// used to search the sentences without a search engine.
+type SearchIndex struct {
+	sentences map[string]Sentence
+	// IDs of the sentences containing a token, sorted.
+	postings map[string][]int32
+	// IDs of the sentences of a language, sorted.
+	byLanguage map[string][]int32
+	all        []int32
+
+	// Random generator of the random sentences, it can't
+	// be used by several requests at once.
+	mutex  sync.Mutex
+	random *rand.Rand
+}
+
+// SearchQuery describes a search in the index.
+type SearchQuery struct {
+	Query string
+	// Languages of the sentences, all the languages when empty.
+	Languages []string
+	// Language the sentences must be translated in.
+	TranslatedIn string
+	WithAudio    bool
+	Offset       int
+	Limit        int
+}
+
+// NewSearchIndex index the sentences.
+func NewSearchIndex(sentences map[string]Sentence) *SearchIndex {
+	index := &SearchIndex{
+		sentences:  sentences,
+		postings:   make(map[string][]int32),
+		byLanguage: make(map[string][]int32),
+		random:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	// Index in the order of the IDs to keep the lists sorted.
+	for _, ID := range SortedIDs(sentences) {
+		sentence := sentences[ID]
+
+		index.all = append(index.all, sentence.ID)
+		index.byLanguage[sentence.Language] = append(index.byLanguage[sentence.Language], sentence.ID)
+
+		// Add the sentence once by token.
+		seen := make(map[string]bool)
+
+		for _, token := range tokenize(sentence.Content) {
+			if !seen[token] {
+				seen[token] = true
+				index.postings[token] = append(index.postings[token], sentence.ID)
+			}
+		}
+	}
+
+	return index
+}
+
+// tokenize split a text in lowercase words. The ideographs and the kana
+// are not separated by spaces, so each of them is a token.
+func tokenize(text string) []string {
+	var tokens []string
+	var word strings.Builder
+
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+
+	flush()
+
+	return tokens
+}
+
+// Sentence returns the sentence with the given ID.
+func (i *SearchIndex) Sentence(ID int32) (Sentence, bool) {
+	sentence, exists := i.sentences[strconv.Itoa(int(ID))]
+
+	return sentence, exists
+}
+
+// Search returns the number of sentences matching the query and the
+// requested page of them. The sentences contain all the words of the
+// query, the shortest sentences first.
+func (i *SearchIndex) Search(query SearchQuery) (int, []Sentence) {
+	var candidates []int32
+
+	tokens := tokenize(query.Query)
+
+	switch {
+	case len(tokens) > 0:
+		candidates = i.intersect(tokens)
+	case len(query.Languages) == 1:
+		candidates = i.byLanguage[query.Languages[0]]
+	default:
+		candidates = i.all
+	}
+
+	// Without words to sort by, the sentences are counted and only
+	// the requested page is kept.
+	sorted := len(tokens) > 0
+	hits := []Sentence{}
+	total := 0
+
+	for _, ID := range candidates {
+		sentence, _ := i.Sentence(ID)
+
+		if len(query.Languages) > 0 && !languageExists(sentence.Language, query.Languages) {
+			continue
+		}
+
+		if query.TranslatedIn != "" && !languageExists(query.TranslatedIn, sentence.TranslatedLanguages) {
+			continue
+		}
+
+		if query.WithAudio && sentence.AudioUsername == "" {
+			continue
+		}
+
+		if sorted || (total >= query.Offset && total-query.Offset < query.Limit) {
+			hits = append(hits, sentence)
+		}
+
+		total++
+	}
+
+	if !sorted {
+		return total, hits
+	}
+
+	// The shortest sentences are the closest to the query.
+	sort.SliceStable(hits, func(a, b int) bool {
+		return utf8.RuneCountInString(hits[a].Content) < utf8.RuneCountInString(hits[b].Content)
+	})
+
+	if query.Offset >= total {
+		return total, []Sentence{}
+	}
+
+	hits = hits[query.Offset:]
+
+	if len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+
+	return total, hits
+}
+
+// intersect returns the IDs of the sentences containing all the tokens.
+func (i *SearchIndex) intersect(tokens []string) []int32 {
+	lists := make([][]int32, len(tokens))
+
+	for j, token := range tokens {
+		lists[j] = i.postings[token]
+	}
+
+	// Start from the shortest list, the result can't be longer.
+	sort.Slice(lists, func(a, b int) bool {
+		return len(lists[a]) < len(lists[b])
+	})
+
+	result := lists[0]
+
+	for _, list := range lists[1:] {
+		var common []int32
+
+		for a, b := 0, 0; a < len(result) && b < len(list); {
+			switch {
+			case result[a] < list[b]:
+				a++
+			case result[a] > list[b]:
+				b++
+			default:
+				common = append(common, result[a])
+				a++
+				b++
+			}
+		}
+
+		result = common
+	}
+
+	return result
+}
+
+// Random returns a random sentence, of the given language if not empty.
+func (i *SearchIndex) Random(language string) (Sentence, error) {
+	IDs := i.all
+
+	if language != "" {
+		IDs = i.byLanguage[language]
+	}
+
+	if len(IDs) == 0 {
+		return Sentence{}, errors.New("there is no sentence in this language")
+	}
+
+	i.mutex.Lock()
+	ID := IDs[i.random.Intn(len(IDs))]
+	i.mutex.Unlock()
+
+	sentence, _ := i.Sentence(ID)
+
+	return sentence, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSearchPagesUnsortedResults(t *testing.T) {
+	sentences := fixtureSentences(t)
+	index := NewSearchIndex(sentences)
+
+	total, all := index.Search(SearchQuery{Limit: len(sentences)})
+
+	if total != len(sentences) || len(all) != total {
+		t.Fatalf("%d sentences found and %d returned, want %d", total, len(all), len(sentences))
+	}
+
+	total, page := index.Search(SearchQuery{Offset: 1, Limit: 2})
+
+	if total != len(sentences) || len(page) != 2 {
+		t.Fatalf("%d sentences found and %d returned, want %d and 2", total, len(page), len(sentences))
+	}
+
+	if page[0].ID != all[1].ID || page[1].ID != all[2].ID {
+		t.Errorf("page = %d, %d, want %d, %d", page[0].ID, page[1].ID, all[1].ID, all[2].ID)
+	}
+
+	if total, page = index.Search(SearchQuery{Offset: total, Limit: 2}); len(page) != 0 {
+		t.Errorf("%d sentences returned past the end, want 0", len(page))
+	}
+}
+
+func TestSentenceRejectsLargeID(t *testing.T) {
+	server := searchServer{index: NewSearchIndex(fixtureSentences(t))}
+
+	// 4294967297 would wrap to the sentence 1.
+	for _, ID := range []string{"4294967297", "abc"} {
+		recorder := httptest.NewRecorder()
+		server.sentence(recorder, httptest.NewRequest(http.MethodGet, "/sentences/"+ID, nil))
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("status of the sentence %s = %d, want %d", ID, recorder.Code, http.StatusBadRequest)
+		}
+	}
+}
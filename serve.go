@@ -0,0 +1,197 @@
+package main
+
+import (
+	json2 "encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// maxSearchLimit is the maximum number of sentences returned by a search.
+const maxSearchLimit = 100
+
+// searchResponse describes the response of the search route.
+type searchResponse struct {
+	Total     int        `json:"total"`
+	Offset    int        `json:"offset"`
+	Limit     int        `json:"limit"`
+	Sentences []Sentence `json:"sentences"`
+}
+
+// sentenceResponse describes a sentence with its translations expanded.
+type sentenceResponse struct {
+	Sentence
+	DirectTranslations   []Sentence `json:"direct_translations"`
+	IndirectTranslations []Sentence `json:"indirect_translations"`
+}
+
+// errorResponse describes an error of the API.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// searchServer serves the HTTP API over the in-memory index.
+type searchServer struct {
+	index *SearchIndex
+}
+
+// Serve index the sentences in memory and serve the search API
+// on the given address until the process is stopped.
+func Serve(sentences map[string]Sentence, address string) {
+	fmt.Print("Indexing the sentences in memory...")
+	server := searchServer{index: NewSearchIndex(sentences)}
+	color.Green("%c[2K\r%d sentences have been indexed in memory", 27, len(sentences))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", server.search)
+	mux.HandleFunc("/sentences/", server.sentence)
+	mux.HandleFunc("/random", server.random)
+
+	color.Cyan("Listening on http://%s", address)
+
+	log.Fatal(http.ListenAndServe(address, mux))
+}
+
+// search handle GET /search?q=&lang=&translated=&audio=&offset=&limit=
+func (s searchServer) search(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	parameters := r.URL.Query()
+
+	query := SearchQuery{
+		Query:        parameters.Get("q"),
+		TranslatedIn: parameters.Get("translated"),
+		WithAudio:    parameters.Get("audio") == "true",
+		Limit:        20,
+	}
+
+	if languages := parameters.Get("lang"); languages != "" {
+		query.Languages = strings.Split(languages, ",")
+	}
+
+	var err error
+
+	if query.Offset, err = intParameter(parameters.Get("offset"), 0); err != nil || query.Offset < 0 {
+		writeError(w, http.StatusBadRequest, "invalid offset")
+		return
+	}
+
+	if query.Limit, err = intParameter(parameters.Get("limit"), query.Limit); err != nil || query.Limit < 1 || query.Limit > maxSearchLimit {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("the limit must be between 1 and %d", maxSearchLimit))
+		return
+	}
+
+	total, sentences := s.index.Search(query)
+
+	writeJSON(w, http.StatusOK, searchResponse{
+		Total:     total,
+		Offset:    query.Offset,
+		Limit:     query.Limit,
+		Sentences: sentences,
+	})
+}
+
+// sentence handle GET /sentences/{id}
+func (s searchServer) sentence(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	// The IDs larger than an int32 don't exist, they are rejected
+	// rather than wrapped to another sentence.
+	ID, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/sentences/"), 10, 32)
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid sentence ID")
+		return
+	}
+
+	sentence, exists := s.index.Sentence(int32(ID))
+
+	if !exists {
+		writeError(w, http.StatusNotFound, "sentence not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.expand(sentence))
+}
+
+// random handle GET /random?lang=
+func (s searchServer) random(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	sentence, err := s.index.Random(r.URL.Query().Get("lang"))
+
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.expand(sentence))
+}
+
+// expand replace the IDs of the translations by the translations.
+func (s searchServer) expand(sentence Sentence) sentenceResponse {
+	response := sentenceResponse{
+		Sentence:             sentence,
+		DirectTranslations:   []Sentence{},
+		IndirectTranslations: []Sentence{},
+	}
+
+	for _, ID := range sentence.DirectRelations {
+		if translation, exists := s.index.Sentence(ID); exists {
+			response.DirectTranslations = append(response.DirectTranslations, translation)
+		}
+	}
+
+	for _, ID := range sentence.IndirectRelations {
+		if translation, exists := s.index.Sentence(ID); exists {
+			response.IndirectTranslations = append(response.IndirectTranslations, translation)
+		}
+	}
+
+	return response
+}
+
+// allowGet check the method is GET, otherwise write an error.
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+
+	return true
+}
+
+// intParameter parse an integer parameter, or returns the default value.
+func intParameter(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+// writeJSON write the value as the JSON response.
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json2.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Cannot write the response: %s", err)
+	}
+}
+
+// writeError write an error as the JSON response.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// snapshotMagic identifies the snapshot files.
+const snapshotMagic = "tatoeba-indexer snapshot"
+
+// snapshotVersion is the version of the snapshot format, increase it
+// when the fields of the sentences change.
+const snapshotVersion = 1
+
+// ManifestFile describes a downloaded file the sentences are parsed from.
+type ManifestFile struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// SnapshotHeader is written before the sentences of a snapshot.
+type SnapshotHeader struct {
+	Magic   string
+	Version int
+	// Fingerprint of the manifest, the key of the snapshot.
+	Fingerprint string
+	Manifest    []ManifestFile
+	// Options of the parser, like the filtered languages.
+	ParseOptions string
+	CreatedAt    time.Time
+	Total        int
+}
+
+// InputManifest returns the manifest of the downloaded files.
+func InputManifest() ([]ManifestFile, error) {
+	var manifest []ManifestFile
+
+	for _, filename := range []string{SentencesDetailed, Links, SentencesWithAudio, Transcriptions} {
+		info, err := os.Stat(os.TempDir() + filename + ".csv")
+
+		if err != nil {
+			return nil, err
+		}
+
+		manifest = append(manifest, ManifestFile{Name: filename, Size: info.Size(), ModTime: info.ModTime()})
+	}
+
+	return manifest, nil
+}
+
+// ManifestFingerprint returns a fingerprint of the files of the manifest,
+// from their names, sizes and modification dates.
+func ManifestFingerprint(manifest []ManifestFile) string {
+	hash := sha256.New()
+
+	for _, file := range manifest {
+		fmt.Fprintf(hash, "%s %d %d\n", file.Name, file.Size, file.ModTime.UnixNano())
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// WriteSnapshot save the parsed and enriched sentences, so they
+// can be loaded without parsing the files again.
+func WriteSnapshot(path string, manifest []ManifestFile, sentences map[string]Sentence) error {
+	file, err := os.Create(path + ".tmp")
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := gob.NewEncoder(writer)
+
+	header := SnapshotHeader{
+		Magic:        snapshotMagic,
+		Version:      snapshotVersion,
+		Fingerprint:  ManifestFingerprint(manifest),
+		Manifest:     manifest,
+		ParseOptions: parseOptions(),
+		CreatedAt:    time.Now(),
+		Total:        len(sentences),
+	}
+
+	if err := encoder.Encode(header); err != nil {
+		return fmt.Errorf("cannot write the snapshot: %s", err)
+	}
+
+	// Encode the sentences one by one to not hold
+	// a second copy of them in memory.
+	for _, sentence := range sentences {
+		if err := encoder.Encode(&sentence); err != nil {
+			return fmt.Errorf("cannot write the snapshot: %s", err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("cannot write the snapshot: %s", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// ReadSnapshot load the sentences of a snapshot with its header.
+func ReadSnapshot(path string) (SnapshotHeader, map[string]Sentence) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		log.Fatalf("Cannot read the snapshot: %s", err)
+	}
+
+	defer file.Close()
+
+	decoder := gob.NewDecoder(bufio.NewReader(file))
+	header, err := decodeSnapshotHeader(decoder)
+
+	if err != nil {
+		log.Fatalf("Cannot read the snapshot \"%s\": %s", path, err)
+	}
+
+	sentences := make(map[string]Sentence, header.Total)
+
+	for i := 0; i < header.Total; i++ {
+		var sentence Sentence
+
+		if err := decoder.Decode(&sentence); err != nil {
+			log.Fatalf("Cannot decode the snapshot: %s", err)
+		}
+
+		// Gob doesn't keep the empty slices, restore them so the
+		// sentences are sent as the parsed ones, with `[]` not `null`.
+		if sentence.DirectRelations == nil {
+			sentence.DirectRelations = make([]int32, 0)
+		}
+
+		if sentence.IndirectRelations == nil {
+			sentence.IndirectRelations = make([]int32, 0)
+		}
+
+		if sentence.TranslatedLanguages == nil {
+			sentence.TranslatedLanguages = make([]string, 0)
+		}
+
+		if sentence.Transcriptions == nil {
+			sentence.Transcriptions = make([]Transcription, 0)
+		}
+
+		sentences[strconv.Itoa(int(sentence.ID))] = sentence
+	}
+
+	return header, sentences
+}
+
+// ReadSnapshotHeader read the header of a snapshot without its sentences.
+func ReadSnapshotHeader(path string) (SnapshotHeader, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return SnapshotHeader{}, err
+	}
+
+	defer file.Close()
+
+	return decodeSnapshotHeader(gob.NewDecoder(bufio.NewReader(file)))
+}
+
+// decodeSnapshotHeader decode the header and check the snapshot
+// has been written in the current format.
+func decodeSnapshotHeader(decoder *gob.Decoder) (SnapshotHeader, error) {
+	var header SnapshotHeader
+
+	if err := decoder.Decode(&header); err != nil {
+		if err == io.EOF {
+			return header, errors.New("the file is empty")
+		}
+
+		return header, fmt.Errorf("not a snapshot: %s", err)
+	}
+
+	if header.Magic != snapshotMagic {
+		return header, errors.New("not a snapshot")
+	}
+
+	if header.Version != snapshotVersion {
+		return header, fmt.Errorf("the snapshot version %d is not supported, build it again", header.Version)
+	}
+
+	return header, nil
+}
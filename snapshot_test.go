@@ -0,0 +1,29 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	sentences := fixtureSentences(t)
+	path := filepath.Join(t.TempDir(), "tatoeba.snapshot")
+
+	if err := WriteSnapshot(path, nil, sentences); err != nil {
+		t.Fatal(err)
+	}
+
+	header, loaded := ReadSnapshot(path)
+
+	if header.Total != len(sentences) || len(loaded) != len(sentences) {
+		t.Fatalf("%d sentences have been loaded, want %d", len(loaded), len(sentences))
+	}
+
+	// The sentences must be sent exactly as the parsed ones,
+	// the orphan sentence 7 has empty relations.
+	for ID, sentence := range sentences {
+		if expected, actual := string(sentence.AppendJSON(nil)), string(loaded[ID].AppendJSON(nil)); actual != expected {
+			t.Errorf("The sentence %s is %s, want %s", ID, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	json2 "encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// CorpusStats describes the content of a Tatoeba export.
+type CorpusStats struct {
+	Sentences int `json:"sentences"`
+	// Lines of the sentences file ignored for their unknown language,
+	// nil when the sentences come from a snapshot.
+	UnknownLanguage *int `json:"unknown_language,omitempty"`
+	// Sentences without any translation.
+	Orphans         int                `json:"orphans"`
+	Links           int                `json:"links"`
+	Languages       []LanguageStats    `json:"languages"`
+	TopContributors []ContributorStats `json:"top_contributors"`
+	// Number of links from a language to another one.
+	TranslationMatrix map[string]map[string]int `json:"translation_matrix"`
+	// Nil when the sentences come from a snapshot.
+	BrokenReferences *BrokenReferences `json:"broken_references,omitempty"`
+}
+
+// LanguageStats describes the sentences of a language.
+type LanguageStats struct {
+	Language              string  `json:"language"`
+	Sentences             int     `json:"sentences"`
+	WithAudio             int     `json:"with_audio"`
+	AudioCoverage         float64 `json:"audio_coverage"`
+	WithTranscription     int     `json:"with_transcription"`
+	TranscriptionCoverage float64 `json:"transcription_coverage"`
+	Orphans               int     `json:"orphans"`
+}
+
+// ContributorStats describes the sentences added by a contributor.
+type ContributorStats struct {
+	Username  string `json:"username"`
+	Sentences int    `json:"sentences"`
+}
+
+// BrokenReferences counts the lines of the files referencing
+// a sentence which doesn't exist in the sentences file.
+type BrokenReferences struct {
+	Links          int `json:"links"`
+	Audio          int `json:"audio"`
+	Transcriptions int `json:"transcriptions"`
+}
+
+// translationPair describes the links between two languages.
+type translationPair struct {
+	from, to string
+	links    int
+}
+
+// ComputeStats parse the files and returns the statistics of the export,
+// with the given number of top contributors.
+func ComputeStats(top int) CorpusStats {
+	var sentences map[string]Sentence
+	var stats CorpusStats
+
+	if fromSnapshotPath != "" {
+		// The references to the missing sentences have
+		// been removed when the snapshot was built.
+		sentences = loadSnapshot(fromSnapshotPath)
+	} else {
+		fmt.Fprint(os.Stderr, "Parsing the files...")
+
+		// The lines ignored by the parser are the ones of an unknown
+		// language, or referencing a sentence which doesn't exist.
+		var unknownLanguage int
+		var brokenReferences BrokenReferences
+
+		sentences, unknownLanguage = ParseSentences()
+		brokenReferences.Audio = ParseSentencesWithAudio(&sentences)
+		brokenReferences.Links = ParseSentencesLink(&sentences)
+		brokenReferences.Transcriptions = ParseTranscriptions(&sentences)
+
+		stats.UnknownLanguage = &unknownLanguage
+		stats.BrokenReferences = &brokenReferences
+
+		fmt.Fprintf(os.Stderr, "%c[2K\r", 27)
+	}
+
+	stats.Sentences = len(sentences)
+	stats.TranslationMatrix = make(map[string]map[string]int)
+
+	languages := make(map[string]*LanguageStats)
+	contributors := make(map[string]int)
+
+	for _, sentence := range sentences {
+		language, exists := languages[sentence.Language]
+
+		if !exists {
+			language = &LanguageStats{Language: sentence.Language}
+			languages[sentence.Language] = language
+		}
+
+		language.Sentences++
+
+		if sentence.AudioUsername != "" {
+			language.WithAudio++
+		}
+
+		if len(sentence.Transcriptions) > 0 {
+			language.WithTranscription++
+		}
+
+		if len(sentence.DirectRelations) == 0 {
+			language.Orphans++
+			stats.Orphans++
+		}
+
+		if sentence.Username != "" {
+			contributors[sentence.Username]++
+		}
+
+		// Count the links by pair of languages.
+		for _, ID := range sentence.DirectRelations {
+			translation := sentences[fmt.Sprint(ID)]
+
+			if stats.TranslationMatrix[sentence.Language] == nil {
+				stats.TranslationMatrix[sentence.Language] = make(map[string]int)
+			}
+
+			stats.TranslationMatrix[sentence.Language][translation.Language]++
+			stats.Links++
+		}
+	}
+
+	// Sort the languages by number of sentences.
+	for _, language := range languages {
+		language.AudioCoverage = percentage(language.WithAudio, language.Sentences)
+		language.TranscriptionCoverage = percentage(language.WithTranscription, language.Sentences)
+
+		stats.Languages = append(stats.Languages, *language)
+	}
+
+	sort.Slice(stats.Languages, func(i, j int) bool {
+		if stats.Languages[i].Sentences != stats.Languages[j].Sentences {
+			return stats.Languages[i].Sentences > stats.Languages[j].Sentences
+		}
+
+		return stats.Languages[i].Language < stats.Languages[j].Language
+	})
+
+	// Keep the contributors with the most sentences.
+	for username, count := range contributors {
+		stats.TopContributors = append(stats.TopContributors, ContributorStats{Username: username, Sentences: count})
+	}
+
+	sort.Slice(stats.TopContributors, func(i, j int) bool {
+		if stats.TopContributors[i].Sentences != stats.TopContributors[j].Sentences {
+			return stats.TopContributors[i].Sentences > stats.TopContributors[j].Sentences
+		}
+
+		return stats.TopContributors[i].Username < stats.TopContributors[j].Username
+	})
+
+	if len(stats.TopContributors) > top {
+		stats.TopContributors = stats.TopContributors[:top]
+	}
+
+	return stats
+}
+
+// percentage returns the percentage of part in total.
+func percentage(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(part) * 100 / float64(total)
+}
+
+// PrintStats print the statistics as tables, or as JSON.
+func PrintStats(stats CorpusStats, format string, top int) {
+	if format == "json" {
+		encoder := json2.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(stats); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	// A snapshot doesn't keep the ignored lines, their counts are unknown.
+	if stats.UnknownLanguage != nil {
+		fmt.Printf("Sentences: %d, ignored for their unknown language: %d\n", stats.Sentences, *stats.UnknownLanguage)
+	} else {
+		fmt.Printf("Sentences: %d, ignored for their unknown language: n/a\n", stats.Sentences)
+	}
+
+	fmt.Printf("Links: %d, sentences without translation: %d\n", stats.Links, stats.Orphans)
+
+	if stats.BrokenReferences != nil {
+		fmt.Printf("Broken references: %d links, %d audio, %d transcriptions\n", stats.BrokenReferences.Links, stats.BrokenReferences.Audio, stats.BrokenReferences.Transcriptions)
+	} else {
+		fmt.Println("Broken references: n/a")
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Println()
+	fmt.Fprintln(writer, "LANGUAGE\tSENTENCES\tAUDIO\tAUDIO %\tTRANSCRIPTIONS\tTRANSCRIPTIONS %\tORPHANS\t")
+
+	for _, language := range stats.Languages {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%.1f\t%d\t%.1f\t%d\t\n", language.Language, language.Sentences, language.WithAudio,
+			language.AudioCoverage, language.WithTranscription, language.TranscriptionCoverage, language.Orphans)
+	}
+
+	writer.Flush()
+
+	fmt.Println()
+	fmt.Fprintln(writer, "CONTRIBUTOR\tSENTENCES\t")
+
+	for _, contributor := range stats.TopContributors {
+		fmt.Fprintf(writer, "%s\t%d\t\n", contributor.Username, contributor.Sentences)
+	}
+
+	writer.Flush()
+
+	// The whole matrix is too large for a table,
+	// print the pairs with the most links.
+	var pairs []translationPair
+
+	for from, translations := range stats.TranslationMatrix {
+		for to, links := range translations {
+			pairs = append(pairs, translationPair{from: from, to: to, links: links})
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].links != pairs[j].links {
+			return pairs[i].links > pairs[j].links
+		}
+
+		return pairs[i].from+pairs[i].to < pairs[j].from+pairs[j].to
+	})
+
+	if len(pairs) > top {
+		pairs = pairs[:top]
+	}
+
+	fmt.Println()
+	fmt.Fprintln(writer, "FROM\tTO\tLINKS\t")
+
+	for _, pair := range pairs {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t\n", pair.from, pair.to, pair.links)
+	}
+
+	writer.Flush()
+}
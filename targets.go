@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	json2 "encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Target describes a search engine the sentences are indexed in,
+// when several engines are indexed from a single parse.
+type Target struct {
+	// Name printed before the output of the target.
+	Name string `json:"name" yaml:"-"`
+	// Engine is the subcommand of the engine, meilisearch or elasticsearch.
+	Engine string `json:"engine" yaml:"engine"`
+	// Index is the name of the index, the --index flag by default.
+	Index string `json:"index" yaml:"index,omitempty"`
+	// Options are the flags of the engine and the global flags,
+	// by their long name, like "host" or "verify".
+	Options map[string]interface{} `json:"options" yaml:"options,omitempty"`
+}
+
+// targetsFile describes the file listing the targets.
+type targetsFile struct {
+	Targets []Target `json:"targets"`
+}
+
+// targetResult describes how the indexing went for a target.
+type targetResult struct {
+	err      error
+	duration time.Duration
+}
+
+// escapeSequence matches the terminal sequences of the output
+// of the targets, like the colors.
+var escapeSequence = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// progressInterval is the minimal interval between two updates
+// of the progress line of the targets.
+const progressInterval = 200 * time.Millisecond
+
+// ReadTargets read and check the targets file.
+func ReadTargets(path string) []Target {
+	content, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		log.Fatalf("Cannot read the targets file: %s", err)
+	}
+
+	var file targetsFile
+
+	if err := json2.Unmarshal(content, &file); err != nil {
+		log.Fatalf("Cannot decode the targets file \"%s\": %s", path, err)
+	}
+
+	if len(file.Targets) == 0 {
+		log.Fatalf("The targets file \"%s\" doesn't contain any target.", path)
+	}
+
+	names := make(map[string]bool)
+
+	for i := range file.Targets {
+		target := &file.Targets[i]
+
+		if target.Engine != meilisearchName && target.Engine != elasticsearchName {
+			log.Fatalf("The target %d has an unknown engine \"%s\", use %s or %s.", i+1, target.Engine, meilisearchName, elasticsearchName)
+		}
+
+		// Name the targets by their engine by default.
+		if target.Name == "" {
+			target.Name = target.Engine
+		}
+
+		if names[target.Name] {
+			log.Fatalf("The target name \"%s\" is used several times, give the targets different names.", target.Name)
+		}
+
+		names[target.Name] = true
+	}
+
+	return file.Targets
+}
+
+// newTargetRun create the run of the target from the CLI arguments
+// changed by the options of the target, its messages are printed
+// to the output. The flags are restored once the run is created.
+func newTargetRun(target Target, output io.Writer) (*indexRun, error) {
+	defer saveFlags()()
+
+	if err := target.applyOptions(); err != nil {
+		return nil, err
+	}
+
+	// The targets are indexed concurrently, a single
+	// terminal can't be used to ask their secrets.
+	if (target.Engine == meilisearchName && isAPIKeyRequired && apiKeyFileMeiliSearch == "" && apiKeyCommandMeiliSearch == "" && os.Getenv(envMeiliSearchAPIKey) == "") ||
+		(target.Engine == elasticsearchName && isPasswordRequired && os.Getenv(envElasticsearchPassword) == "") {
+		return nil, fmt.Errorf("target \"%s\": the secrets can't be asked in the terminal, use an environment variable", target.Name)
+	}
+
+	// Each target has its own failed sentences.
+	if _, exists := target.Options["dead-letter"]; !exists && deadLetterPath == defaultDeadLetterPath {
+		deadLetterPath = fmt.Sprintf("%s.%s.%s", IndexName, target.Name, defaultDeadLetterPath)
+	}
+
+	return newIndexRun(target.Engine, target.Name, output), nil
+}
+
+// checkStateFiles returns an error if several runs use the same
+// file to keep their state, they would overwrite each other.
+func checkStateFiles(targets []Target, runs []*indexRun) error {
+	users := make(map[string]string)
+
+	for i, run := range runs {
+		paths := []string{run.checkpointPath, run.hashesPath}
+
+		if elasticsearchClient, ok := run.client.(*Elasticsearch); ok {
+			paths = append(paths, elasticsearchClient.deadLetterPath)
+		}
+
+		for _, path := range paths {
+			if name, used := users[path]; used {
+				return fmt.Errorf("the targets \"%s\" and \"%s\" both use the file \"%s\", give them different files in their options", name, targets[i].Name, path)
+			}
+
+			users[path] = targets[i].Name
+		}
+	}
+
+	return nil
+}
+
+// IndexTargets index the sentences in all the targets concurrently,
+// and returns false if at least one of them failed.
+func IndexTargets(targets []Target, sentences map[string]Sentence) bool {
+	output := newTargetOutput(targets)
+	runs := make([]*indexRun, len(targets))
+	readers := make([]*io.PipeReader, len(targets))
+	writers := make([]*io.PipeWriter, len(targets))
+
+	// Create all the runs before indexing anything,
+	// an invalid target doesn't start any run.
+	for i, target := range targets {
+		readers[i], writers[i] = io.Pipe()
+
+		run, err := newTargetRun(target, writers[i])
+
+		if err != nil {
+			log.Fatalf("Cannot index the targets: %s", err)
+		}
+
+		runs[i] = run
+	}
+
+	if err := checkStateFiles(targets, runs); err != nil {
+		log.Fatalf("Cannot index the targets: %s", err)
+	}
+
+	results := make([]targetResult, len(targets))
+
+	var wait sync.WaitGroup
+
+	for i, target := range targets {
+		wait.Add(1)
+
+		go func(i int, target Target) {
+			defer wait.Done()
+
+			// Print the output of the target until its run ends.
+			forwarded := make(chan struct{})
+
+			go func() {
+				forwardOutput(target.Name, readers[i], output)
+				close(forwarded)
+			}()
+
+			startedAt := time.Now()
+			// All the targets index the same sentences, even when resumed,
+			// a failing target stops without stopping the others.
+			err := runs[i].index(func() map[string]Sentence {
+				return sentences
+			})
+
+			writers[i].Close()
+			<-forwarded
+
+			results[i] = targetResult{err: err, duration: time.Since(startedAt).Round(time.Second)}
+		}(i, target)
+	}
+
+	wait.Wait()
+
+	// Print the final status of every target.
+	fmt.Printf("%c[2K\r", 27)
+
+	succeeded := true
+
+	for i, target := range targets {
+		if results[i].err != nil {
+			color.Red("[%s] failed after %s: %s", target.Name, results[i].duration, results[i].err)
+			succeeded = false
+		} else {
+			color.Green("[%s] succeeded in %s", target.Name, results[i].duration)
+		}
+	}
+
+	return succeeded
+}
+
+// forwardOutput print the output of a target until it is closed.
+func forwardOutput(name string, reader io.Reader, output *targetOutput) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(scanLinesAndProgress)
+
+	for scanner.Scan() {
+		token := scanner.Text()
+
+		// Remove the colors and the sequence clearing the terminal line.
+		text := strings.TrimSpace(escapeSequence.ReplaceAllString(token[:len(token)-1], ""))
+
+		if text == "" {
+			continue
+		}
+
+		// The lines ending with a carriage return are progress updates.
+		if strings.HasSuffix(token, "\r") {
+			output.progress(name, text)
+		} else {
+			output.line(name, text)
+		}
+	}
+}
+
+// scanLinesAndProgress split the output on the new lines and
+// on the carriage returns, keeping the separator in the token.
+func scanLinesAndProgress(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+
+	// The last line has no separator.
+	if atEOF && len(data) > 0 {
+		return len(data), append(data[:len(data):len(data)], '\n'), nil
+	}
+
+	return 0, nil, nil
+}
+
+// targetOutput print the output of the targets indexed concurrently,
+// the lines prefixed by the name of the target and the progress of all
+// the targets on a single line.
+type targetOutput struct {
+	mutex       sync.Mutex
+	names       []string
+	progresses  map[string]string
+	lastPrinted time.Time
+}
+
+// newTargetOutput create the output of the given targets.
+func newTargetOutput(targets []Target) *targetOutput {
+	output := &targetOutput{progresses: make(map[string]string)}
+
+	for _, target := range targets {
+		output.names = append(output.names, target.Name)
+	}
+
+	return output
+}
+
+// line print a line of a target above the progress line.
+func (o *targetOutput) line(name, text string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	fmt.Printf("%c[2K\r[%s] %s\n", 27, name, text)
+	o.printProgress()
+}
+
+// progress update the progress of a target.
+func (o *targetOutput) progress(name, text string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.progresses[name] = text
+
+	// Don't flood the terminal with the progress of every sentence.
+	if time.Since(o.lastPrinted) >= progressInterval {
+		o.printProgress()
+	}
+}
+
+// printProgress print the progress of all the targets on the same line.
+func (o *targetOutput) printProgress() {
+	var progresses []string
+
+	for _, name := range o.names {
+		if progress := o.progresses[name]; progress != "" {
+			progresses = append(progresses, fmt.Sprintf("[%s] %s", name, progress))
+		}
+	}
+
+	fmt.Printf("%c[2K\r%s", 27, strings.Join(progresses, " | "))
+	o.lastPrinted = time.Now()
+}
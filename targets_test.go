@@ -0,0 +1,128 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/integrii/flaggy"
+)
+
+// newTestTargets returns a MeiliSearch and an Elasticsearch target
+// using the fake engines, the files of the runs are in a temporary directory.
+func newTestTargets(t *testing.T, meiliSearch *fakeMeiliSearch, elasticsearch *fakeElasticsearch) []Target {
+	// The options of the targets are the flags.
+	flaggy.ResetParser()
+	defineCLIArguments()
+
+	directory := t.TempDir()
+
+	return []Target{
+		{
+			Name:    "meili",
+			Engine:  meilisearchName,
+			Options: map[string]interface{}{"host": meiliSearch.server.URL, "batch-size": 2, "compression": "none"},
+		},
+		{
+			Name:   "es",
+			Engine: elasticsearchName,
+			Index:  "sentences",
+			Options: map[string]interface{}{
+				"host":        elasticsearch.server.URL,
+				"dead-letter": filepath.Join(directory, "failed_sentences.jsonl"),
+				"hashes":      filepath.Join(directory, "es.hashes"),
+			},
+		},
+	}
+}
+
+func TestIndexTargetsShareSentences(t *testing.T) {
+	sentences := fixtureSentences(t)
+	meiliSearch := newFakeMeiliSearch(t)
+	elasticsearch := newFakeElasticsearch(t)
+
+	if !IndexTargets(newTestTargets(t, meiliSearch, elasticsearch), sentences) {
+		t.Fatal("A target failed")
+	}
+
+	if documents := len(meiliSearch.indexes[IndexName].documents); documents != len(sentences) {
+		t.Errorf("MeiliSearch contains %d documents, want %d", documents, len(sentences))
+	}
+
+	if documents := len(elasticsearch.indexes[elasticsearch.aliases["sentences"]].documents); documents != len(sentences) {
+		t.Errorf("Elasticsearch contains %d documents, want %d", documents, len(sentences))
+	}
+
+	// The options of the targets only apply to their run.
+	if hostMeiliSearch != "127.0.0.1:7700" || IndexName != "tatoeba" {
+		t.Errorf("host = %s and index = %s after the targets, want the defaults", hostMeiliSearch, IndexName)
+	}
+}
+
+func TestIndexTargetsFailureStopsOnlyItsTarget(t *testing.T) {
+	sentences := fixtureSentences(t)
+	meiliSearch := newFakeMeiliSearch(t)
+	elasticsearch := newFakeElasticsearch(t)
+
+	// Elasticsearch rejects every sentence, more than --max-failures.
+	for ID := range sentences {
+		elasticsearch.reject[ID] = "failed to parse"
+	}
+
+	if IndexTargets(newTestTargets(t, meiliSearch, elasticsearch), sentences) {
+		t.Fatal("The failure of a target hasn't been reported")
+	}
+
+	if documents := len(meiliSearch.indexes[IndexName].documents); documents != len(sentences) {
+		t.Errorf("MeiliSearch contains %d documents, want %d", documents, len(sentences))
+	}
+
+	if _, exists := elasticsearch.aliases["sentences"]; exists {
+		t.Error("The alias has been moved to the failed index")
+	}
+}
+
+func TestIndexTargetsUnwritableDeadLetter(t *testing.T) {
+	sentences := fixtureSentences(t)
+	meiliSearch := newFakeMeiliSearch(t)
+	elasticsearch := newFakeElasticsearch(t)
+	elasticsearch.reject["3"] = "failed to parse"
+
+	// The rejected sentence can't be written to the dead-letter file,
+	// the failure of the worker stops Elasticsearch only.
+	targets := newTestTargets(t, meiliSearch, elasticsearch)
+	targets[1].Options["dead-letter"] = filepath.Join(t.TempDir(), "missing", "failed_sentences.jsonl")
+	targets[1].Options["max-failures"] = 1
+
+	if IndexTargets(targets, sentences) {
+		t.Fatal("The failure of the dead-letter file hasn't been reported")
+	}
+
+	if documents := len(meiliSearch.indexes[IndexName].documents); documents != len(sentences) {
+		t.Errorf("MeiliSearch contains %d documents, want %d", documents, len(sentences))
+	}
+
+	if _, exists := elasticsearch.aliases["sentences"]; exists {
+		t.Error("The alias has been moved to the failed index")
+	}
+}
+
+func TestCheckStateFilesSharedHashes(t *testing.T) {
+	targets := newTestTargets(t, newFakeMeiliSearch(t), newFakeElasticsearch(t))
+	targets[0].Options["hashes"] = targets[1].Options["hashes"]
+
+	var runs []*indexRun
+
+	for _, target := range targets {
+		run, err := newTargetRun(target, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		runs = append(runs, run)
+	}
+
+	if err := checkStateFiles(targets, runs); err == nil {
+		t.Error("The targets sharing the hashes file are accepted")
+	}
+}
@@ -0,0 +1,36 @@
+package tatoeba
+
+// Indexer define the methods indexers need to implement.
+type Indexer interface {
+	Init() error
+	// Resume continue the build of the index of an interrupted run.
+	Resume() error
+	Index(map[string]Sentence) error
+	Verifier
+	DeltaIndexer
+}
+
+// DeltaIndexer define the methods indexers need to implement
+// to only index the sentences changed since the last run.
+type DeltaIndexer interface {
+	// Connect create the client without creating a new index.
+	Connect() error
+	// Push index the given sentences in the live index,
+	// it returns the IDs of the sentences the engine rejected.
+	Push(map[string]Sentence) ([]string, error)
+	// Delete remove the given sentences from the live index,
+	// it returns the IDs of the sentences the engine didn't delete.
+	Delete([]string) ([]string, error)
+}
+
+// Verifier define the methods indexers need to implement
+// to verify the sentences have been indexed.
+type Verifier interface {
+	// CountDocuments returns the number of documents in the index.
+	CountDocuments() (int, error)
+	// DocumentIDs returns the IDs of all the documents in the index.
+	DocumentIDs() (map[string]bool, error)
+	// FailureReasons returns the reasons given by the engine
+	// for the sentences it rejected, by sentence ID.
+	FailureReasons() (map[string]string, error)
+}
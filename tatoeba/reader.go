@@ -0,0 +1,270 @@
+package tatoeba
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxLineSize is the maximum size of a line of the export files.
+const maxLineSize = 1024 * 1024
+
+// Link describes a line of the file `links.csv`, a translation
+// from a sentence to another one.
+type Link struct {
+	From int32
+	To   int32
+}
+
+// Audio describes a line of the file `sentences_with_audio.csv`.
+type Audio struct {
+	SentenceID int32
+	Username   string
+}
+
+// SentenceTranscription describes a line of the file `transcriptions.csv`.
+type SentenceTranscription struct {
+	SentenceID int32
+	Language   string
+	Transcription
+}
+
+// reader split the lines of an export file in columns.
+type reader struct {
+	scanner *bufio.Scanner
+	line    int
+	err     error
+}
+
+// newReader returns a reader of the lines of r.
+func newReader(r io.Reader) reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineSize)
+
+	return reader{scanner: scanner}
+}
+
+// next read the next line, it returns false at the end of the
+// file or when the line doesn't have the number of columns.
+func (r *reader) next(columns int) ([]string, bool) {
+	if r.err != nil {
+		return nil, false
+	}
+
+	if !r.scanner.Scan() {
+		r.err = r.scanner.Err()
+		return nil, false
+	}
+
+	r.line++
+
+	line := strings.Split(r.scanner.Text(), "\t")
+
+	if len(line) < columns {
+		r.err = fmt.Errorf("line %d: %d columns expected, %d found", r.line, columns, len(line))
+		return nil, false
+	}
+
+	return line, true
+}
+
+// parseID convert a sentence ID from a string to an int.
+func (r *reader) parseID(value string) (int32, bool) {
+	ID, err := strconv.ParseInt(value, 10, 32)
+
+	if err != nil {
+		r.err = fmt.Errorf("line %d: invalid sentence ID \"%s\"", r.line, value)
+		return 0, false
+	}
+
+	return int32(ID), true
+}
+
+// Err returns the first error met by the reader, nil at the end of the file.
+func (r *reader) Err() error {
+	return r.err
+}
+
+// SentenceReader reads the sentences of the file `sentences_detailed.csv`.
+type SentenceReader struct {
+	reader
+	sentence Sentence
+	skipped  int
+}
+
+// NewSentenceReader returns a reader of the sentences of r.
+func NewSentenceReader(r io.Reader) *SentenceReader {
+	return &SentenceReader{reader: newReader(r)}
+}
+
+// Next read the next sentence, the sentences of an unknown language
+// are skipped. It returns false at the end of the file or on error.
+func (r *SentenceReader) Next() bool {
+	for {
+		line, ok := r.next(6)
+
+		if !ok {
+			return false
+		}
+
+		// If the language code is not 3 characters,
+		// ignore the line.
+		if len(line[1]) < 3 {
+			r.skipped++
+			continue
+		}
+
+		ID, ok := r.parseID(line[0])
+
+		if !ok {
+			return false
+		}
+
+		r.sentence = Sentence{
+			ID:                  ID,
+			Language:            line[1],
+			Content:             line[2],
+			Username:            line[3],
+			AddedAt:             parseDate(line[4]),
+			UpdatedAt:           parseDate(line[5]),
+			DirectRelations:     make([]int32, 0),
+			IndirectRelations:   make([]int32, 0),
+			TranslatedLanguages: make([]string, 0),
+			AudioUsername:       "",
+			Transcriptions:      make([]Transcription, 0),
+		}
+
+		return true
+	}
+}
+
+// Sentence returns the sentence read by Next.
+func (r *SentenceReader) Sentence() Sentence {
+	return r.sentence
+}
+
+// Skipped returns the number of sentences skipped for their unknown language.
+func (r *SentenceReader) Skipped() int {
+	return r.skipped
+}
+
+// parseDate returns an empty date if the csv value is \N or a zero date.
+func parseDate(date string) string {
+	if date == "\\N" || date == "0000-00-00 00:00:00" {
+		return ""
+	}
+
+	return date
+}
+
+// LinkReader reads the links of the file `links.csv`.
+type LinkReader struct {
+	reader
+	link Link
+}
+
+// NewLinkReader returns a reader of the links of r.
+func NewLinkReader(r io.Reader) *LinkReader {
+	return &LinkReader{reader: newReader(r)}
+}
+
+// Next read the next link, it returns false at the end of the file or on error.
+func (r *LinkReader) Next() bool {
+	line, ok := r.next(2)
+
+	if !ok {
+		return false
+	}
+
+	if r.link.From, ok = r.parseID(line[0]); !ok {
+		return false
+	}
+
+	r.link.To, ok = r.parseID(line[1])
+
+	return ok
+}
+
+// Link returns the link read by Next.
+func (r *LinkReader) Link() Link {
+	return r.link
+}
+
+// AudioReader reads the audio of the file `sentences_with_audio.csv`.
+type AudioReader struct {
+	reader
+	audio Audio
+}
+
+// NewAudioReader returns a reader of the audio of r.
+func NewAudioReader(r io.Reader) *AudioReader {
+	return &AudioReader{reader: newReader(r)}
+}
+
+// Next read the next audio, it returns false at the end of the file or on error.
+func (r *AudioReader) Next() bool {
+	line, ok := r.next(2)
+
+	if !ok {
+		return false
+	}
+
+	if r.audio.SentenceID, ok = r.parseID(line[0]); !ok {
+		return false
+	}
+
+	r.audio.Username = line[1]
+
+	return true
+}
+
+// Audio returns the audio read by Next.
+func (r *AudioReader) Audio() Audio {
+	return r.audio
+}
+
+// TranscriptionReader reads the transcriptions of the file `transcriptions.csv`.
+type TranscriptionReader struct {
+	reader
+	transcription SentenceTranscription
+}
+
+// NewTranscriptionReader returns a reader of the transcriptions of r.
+func NewTranscriptionReader(r io.Reader) *TranscriptionReader {
+	return &TranscriptionReader{reader: newReader(r)}
+}
+
+// Next read the next transcription, it returns false
+// at the end of the file or on error.
+func (r *TranscriptionReader) Next() bool {
+	line, ok := r.next(5)
+
+	if !ok {
+		return false
+	}
+
+	ID, ok := r.parseID(line[0])
+
+	if !ok {
+		return false
+	}
+
+	r.transcription = SentenceTranscription{
+		SentenceID: ID,
+		Language:   line[1],
+		Transcription: Transcription{
+			ScriptName:    line[2],
+			Username:      line[3],
+			Transcription: line[4],
+		},
+	}
+
+	return true
+}
+
+// Transcription returns the transcription read by Next.
+func (r *TranscriptionReader) Transcription() SentenceTranscription {
+	return r.transcription
+}
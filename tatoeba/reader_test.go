@@ -0,0 +1,83 @@
+package tatoeba
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSentenceReaderSkipsUnknownLanguage(t *testing.T) {
+	reader := NewSentenceReader(strings.NewReader("1\teng\tHi.\tCK\t\\N\t\\N\n2\t\\N\tHm.\tCK\t\\N\t\\N\n3\t\tOh.\t\t\\N\t\\N\n"))
+
+	count := 0
+
+	for reader.Next() {
+		count++
+	}
+
+	if err := reader.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 || reader.Skipped() != 2 {
+		t.Errorf("%d sentences read and %d skipped, want 1 and 2", count, reader.Skipped())
+	}
+}
+
+func TestReadersErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		read  func(input string) error
+		input string
+		err   string
+	}{
+		{
+			name:  "sentence missing columns",
+			read:  func(input string) error { _, _, err := ReadSentences(strings.NewReader(input)); return err },
+			input: "1\teng\tHi.\tCK\t\\N\t\\N\n2\teng\tHi.\n",
+			err:   "line 2: 6 columns expected, 3 found",
+		},
+		{
+			name:  "sentence invalid ID",
+			read:  func(input string) error { _, _, err := ReadSentences(strings.NewReader(input)); return err },
+			input: "one\teng\tHi.\tCK\t\\N\t\\N\n",
+			err:   "line 1: invalid sentence ID \"one\"",
+		},
+		{
+			name: "link invalid target",
+			read: func(input string) error {
+				_, err := AddLinks(map[string]Sentence{}, strings.NewReader(input))
+				return err
+			},
+			input: "1\t2\n1\t\n",
+			err:   "line 2: invalid sentence ID \"\"",
+		},
+		{
+			name: "audio missing username",
+			read: func(input string) error {
+				_, err := AddAudio(map[string]Sentence{}, strings.NewReader(input))
+				return err
+			},
+			input: "1\n",
+			err:   "line 1: 2 columns expected, 1 found",
+		},
+		{
+			name: "transcription missing columns",
+			read: func(input string) error {
+				_, err := AddTranscriptions(map[string]Sentence{}, strings.NewReader(input))
+				return err
+			},
+			input: "1\tjpn\tHrkt\n",
+			err:   "line 1: 5 columns expected, 3 found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.read(test.input)
+
+			if err == nil || err.Error() != test.err {
+				t.Errorf("error = %v, want %s", err, test.err)
+			}
+		})
+	}
+}
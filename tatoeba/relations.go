@@ -0,0 +1,172 @@
+package tatoeba
+
+import (
+	"io"
+	"strconv"
+)
+
+// ReadSentences read the sentences of the file `sentences_detailed.csv`
+// and returns a map of `Sentence` by ID, with the number of sentences
+// skipped for their unknown language.
+func ReadSentences(r io.Reader) (map[string]Sentence, int, error) {
+	reader := NewSentenceReader(r)
+	sentences := make(map[string]Sentence)
+
+	for reader.Next() {
+		sentence := reader.Sentence()
+		sentences[strconv.Itoa(int(sentence.ID))] = sentence
+	}
+
+	return sentences, reader.Skipped(), reader.Err()
+}
+
+// AddLinks read the file `links.csv` and add the direct
+// translations between the sentences. The links to or from
+// a sentence missing in the map are ignored and counted.
+func AddLinks(sentences map[string]Sentence, r io.Reader) (ignored int, err error) {
+	reader := NewLinkReader(r)
+
+	for reader.Next() {
+		link := reader.Link()
+		fromID := strconv.Itoa(int(link.From))
+
+		// Get the sentences from the map.
+		fromSentence, fromIDExist := sentences[fromID]
+		toSentence, toIDExist := sentences[strconv.Itoa(int(link.To))]
+
+		// Insert the relation if both ids exists in the sentences map.
+		if fromIDExist && toIDExist {
+			fromSentence.DirectRelations = append(fromSentence.DirectRelations, toSentence.ID)
+
+			// Add the translated language if not present in the array.
+			if !containsLanguage(toSentence.Language, fromSentence.TranslatedLanguages) {
+				fromSentence.TranslatedLanguages = append(fromSentence.TranslatedLanguages, toSentence.Language)
+			}
+
+			sentences[fromID] = fromSentence
+		} else {
+			ignored++
+		}
+	}
+
+	return ignored, reader.Err()
+}
+
+// AddIndirectRelations add the translations of the translations of
+// each sentence as its indirect translations. The direct translations
+// must have been added with AddLinks.
+func AddIndirectRelations(sentences map[string]Sentence) {
+	// Loop over all sentences.
+	for ID, sentence := range sentences {
+		// If the sentence haven't direct relation, skip here.
+		if len(sentence.DirectRelations) == 0 {
+			continue
+		}
+
+		// Loop over all direct relations.
+		for _, directSentenceID := range sentence.DirectRelations {
+			// Get the sentence from the ID.
+			directSentence := sentences[strconv.Itoa(int(directSentenceID))]
+
+			// Loop over all the direct relation sentence direct relations.
+		DirectRelationLoop:
+			for _, directDirectSentenceID := range directSentence.DirectRelations {
+				// Continue to the next sentence if the direc direct sentence ID
+				// is the same as the sentence ID.
+				if directDirectSentenceID == sentence.ID {
+					continue
+				}
+
+				// Check if the direct direct sentence is already
+				// inside the direct and indirect relations
+				for _, directRelationSentenceID := range sentence.DirectRelations {
+					if directDirectSentenceID == directRelationSentenceID {
+						continue DirectRelationLoop
+					}
+				}
+
+				for _, indirectRelationSentenceID := range sentence.IndirectRelations {
+					if directDirectSentenceID == indirectRelationSentenceID {
+						continue DirectRelationLoop
+					}
+				}
+
+				// Add the direct direct relation to the indirect relation.
+				sentence.IndirectRelations = append(sentence.IndirectRelations, directDirectSentenceID)
+
+				// Get the direct direct sentence.
+				directDirectSentence := sentences[strconv.Itoa(int(directDirectSentenceID))]
+
+				// Add the language if not in the translated languages.
+				if !containsLanguage(directDirectSentence.Language, sentence.TranslatedLanguages) {
+					sentence.TranslatedLanguages = append(sentence.TranslatedLanguages, directDirectSentence.Language)
+				}
+			}
+		}
+
+		// Update sentence.
+		sentences[ID] = sentence
+	}
+}
+
+// AddAudio read the file `sentences_with_audio.csv` and set the
+// `AudioUsername` of the sentences with the audio recorder username.
+// The audio of the sentences missing in the map are ignored and counted.
+func AddAudio(sentences map[string]Sentence, r io.Reader) (ignored int, err error) {
+	reader := NewAudioReader(r)
+
+	for reader.Next() {
+		audio := reader.Audio()
+		ID := strconv.Itoa(int(audio.SentenceID))
+
+		if sentence, exists := sentences[ID]; exists {
+			sentence.AudioUsername = audio.Username
+			sentences[ID] = sentence
+		} else {
+			ignored++
+		}
+	}
+
+	return ignored, reader.Err()
+}
+
+// AddTranscriptions read the file `transcriptions.csv` and add the
+// transcriptions to the sentences. The transcriptions of the sentences
+// missing in the map are ignored and counted.
+func AddTranscriptions(sentences map[string]Sentence, r io.Reader) (ignored int, err error) {
+	reader := NewTranscriptionReader(r)
+
+	for reader.Next() {
+		transcription := reader.Transcription()
+		ID := strconv.Itoa(int(transcription.SentenceID))
+
+		if sentence, exists := sentences[ID]; exists {
+			sentence.Transcriptions = append(sentence.Transcriptions, transcription.Transcription)
+			sentences[ID] = sentence
+		} else {
+			ignored++
+		}
+	}
+
+	return ignored, reader.Err()
+}
+
+// FilterLanguages remove the sentences which are not in one of the given languages.
+func FilterLanguages(sentences map[string]Sentence, languages []string) {
+	for ID, sentence := range sentences {
+		if !containsLanguage(sentence.Language, languages) {
+			delete(sentences, ID)
+		}
+	}
+}
+
+// containsLanguage check if the given language exists in the array of languages.
+func containsLanguage(languageToFind string, languages []string) bool {
+	for _, language := range languages {
+		if language == languageToFind {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,180 @@
+package tatoeba
+
+import (
+	"bytes"
+	json2 "encoding/json"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// update rewrite the golden files with the output of the parser,
+// run `go test ./tatoeba -update` after changing the fixture.
+var update = flag.Bool("update", false, "update the golden files")
+
+// exportPath is the directory of the fixture export.
+var exportPath = filepath.Join("testdata", "export")
+
+// readFixture parse a file of the fixture export with the given function.
+func readFixture(t *testing.T, filename string, parse func(r io.Reader) error) {
+	t.Helper()
+
+	file, err := os.Open(filepath.Join(exportPath, filename))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	if err := parse(file); err != nil {
+		t.Fatalf("Cannot parse \"%s\": %s", filename, err)
+	}
+}
+
+// readExport parse the fixture export like the command does.
+func readExport(t *testing.T) map[string]Sentence {
+	t.Helper()
+
+	var sentences map[string]Sentence
+
+	readFixture(t, "sentences_detailed.csv", func(r io.Reader) (err error) {
+		sentences, _, err = ReadSentences(r)
+		return err
+	})
+
+	readFixture(t, "sentences_with_audio.csv", func(r io.Reader) error {
+		_, err := AddAudio(sentences, r)
+		return err
+	})
+
+	readFixture(t, "links.csv", func(r io.Reader) error {
+		_, err := AddLinks(sentences, r)
+		return err
+	})
+
+	AddIndirectRelations(sentences)
+
+	readFixture(t, "transcriptions.csv", func(r io.Reader) error {
+		_, err := AddTranscriptions(sentences, r)
+		return err
+	})
+
+	return sentences
+}
+
+func TestReadExportGolden(t *testing.T) {
+	actual, err := json2.MarshalIndent(readExport(t), "", "  ")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual = append(actual, '\n')
+	golden := filepath.Join("testdata", "sentences.golden.json")
+
+	if *update {
+		if err := ioutil.WriteFile(golden, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := ioutil.ReadFile(golden)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("The parsed sentences differ from \"%s\", run with -update if the change is expected:\n%s", golden, actual)
+	}
+}
+
+func TestReadExportRelations(t *testing.T) {
+	sentences := readExport(t)
+
+	// The sentence of unknown language is skipped, with its links.
+	if _, exists := sentences["5"]; exists {
+		t.Error("The sentence 5 of unknown language has been parsed")
+	}
+
+	if relations := sentences["1"].DirectRelations; len(relations) != 2 {
+		t.Errorf("The sentence 1 has the direct translations %v, want [2 3]", relations)
+	}
+
+	// The translations of the translations, without the sentence itself.
+	if relations := sentences["4"].IndirectRelations; len(relations) != 1 || relations[0] != 1 {
+		t.Errorf("The sentence 4 has the indirect translations %v, want [1]", relations)
+	}
+
+	if languages := sentences["4"].TranslatedLanguages; len(languages) != 2 || languages[0] != "jpn" || languages[1] != "eng" {
+		t.Errorf("The sentence 4 is translated in %v, want [jpn eng]", languages)
+	}
+
+	// The link to a missing sentence is ignored.
+	if relations := sentences["6"].DirectRelations; len(relations) != 0 {
+		t.Errorf("The sentence 6 has the direct translations %v, want none", relations)
+	}
+
+	if transcriptions := sentences["4"].Transcriptions; len(transcriptions) != 2 {
+		t.Errorf("The sentence 4 has %d transcriptions, want 2", len(transcriptions))
+	}
+
+	if date := sentences["3"].AddedAt; date != "" {
+		t.Errorf("The zero date of the sentence 3 is \"%s\", want an empty date", date)
+	}
+}
+
+func TestReadExportIgnored(t *testing.T) {
+	var sentences map[string]Sentence
+	var skipped, links, audio, transcriptions int
+
+	readFixture(t, "sentences_detailed.csv", func(r io.Reader) (err error) {
+		sentences, skipped, err = ReadSentences(r)
+		return err
+	})
+
+	readFixture(t, "links.csv", func(r io.Reader) (err error) {
+		links, err = AddLinks(sentences, r)
+		return err
+	})
+
+	readFixture(t, "sentences_with_audio.csv", func(r io.Reader) (err error) {
+		audio, err = AddAudio(sentences, r)
+		return err
+	})
+
+	readFixture(t, "transcriptions.csv", func(r io.Reader) (err error) {
+		transcriptions, err = AddTranscriptions(sentences, r)
+		return err
+	})
+
+	// The sentence 5 of unknown language is skipped, the lines
+	// referencing it or the missing sentences are ignored.
+	if skipped != 1 {
+		t.Errorf("%d sentences have been skipped, want 1", skipped)
+	}
+
+	if links != 5 || audio != 1 || transcriptions != 1 {
+		t.Errorf("%d links, %d audio and %d transcriptions have been ignored, want 5, 1 and 1", links, audio, transcriptions)
+	}
+}
+
+func TestFilterLanguages(t *testing.T) {
+	sentences := readExport(t)
+
+	FilterLanguages(sentences, []string{"eng", "fra"})
+
+	for ID, sentence := range sentences {
+		if sentence.Language != "eng" && sentence.Language != "fra" {
+			t.Errorf("The sentence %s in \"%s\" has not been removed", ID, sentence.Language)
+		}
+	}
+
+	if len(sentences) != 3 {
+		t.Errorf("%d sentences have been kept, want 3", len(sentences))
+	}
+}
@@ -0,0 +1,29 @@
+// Package tatoeba parses the exports of Tatoeba (https://tatoeba.org),
+// adds the relations between the sentences, their audio and their
+// transcriptions, and describes the indexers sending them to a search engine.
+//
+// The functions never exit the program, they return the errors.
+package tatoeba
+
+// Sentence describes the fields to index.
+type Sentence struct {
+	ID                  int32           `json:"id"`
+	Language            string          `json:"language"`
+	Content             string          `json:"content"`
+	Username            string          `json:"username"`
+	AddedAt             string          `json:"added_at,omitempty"`
+	UpdatedAt           string          `json:"updated_at,omitempty"`
+	DirectRelations     []int32         `json:"direct_translations"`
+	IndirectRelations   []int32         `json:"indirect_translations"`
+	TranslatedLanguages []string        `json:"translated_languages"`
+	AudioUsername       string          `json:"audio_username,omitempty"`
+	Transcriptions      []Transcription `json:"transcriptions,omitempty"`
+}
+
+// Transcription describes the fields used to simplify
+// reading languages like Chinese, Cantonese or Japanese.
+type Transcription struct {
+	ScriptName    string `json:"script_name"`
+	Username      string `json:"username"`
+	Transcription string `json:"transcription"`
+}
@@ -0,0 +1,168 @@
+package tatoeba
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// hexDigits are used to escape the control characters.
+const hexDigits = "0123456789abcdef"
+
+// AppendJSON append the JSON encoding of the sentence to dst and returns
+// the extended buffer. The output is the same as `json.Marshal` but
+// without reflection, to encode the millions of sentences faster.
+func (s Sentence) AppendJSON(dst []byte) []byte {
+	dst = append(dst, `{"id":`...)
+	dst = strconv.AppendInt(dst, int64(s.ID), 10)
+	dst = append(dst, `,"language":`...)
+	dst = appendJSONString(dst, s.Language)
+	dst = append(dst, `,"content":`...)
+	dst = appendJSONString(dst, s.Content)
+	dst = append(dst, `,"username":`...)
+	dst = appendJSONString(dst, s.Username)
+
+	if s.AddedAt != "" {
+		dst = append(dst, `,"added_at":`...)
+		dst = appendJSONString(dst, s.AddedAt)
+	}
+
+	if s.UpdatedAt != "" {
+		dst = append(dst, `,"updated_at":`...)
+		dst = appendJSONString(dst, s.UpdatedAt)
+	}
+
+	dst = append(dst, `,"direct_translations":`...)
+	dst = appendJSONIDs(dst, s.DirectRelations)
+	dst = append(dst, `,"indirect_translations":`...)
+	dst = appendJSONIDs(dst, s.IndirectRelations)
+
+	dst = append(dst, `,"translated_languages":`...)
+
+	if s.TranslatedLanguages == nil {
+		dst = append(dst, "null"...)
+	} else {
+		dst = append(dst, '[')
+
+		for i, language := range s.TranslatedLanguages {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+
+			dst = appendJSONString(dst, language)
+		}
+
+		dst = append(dst, ']')
+	}
+
+	if s.AudioUsername != "" {
+		dst = append(dst, `,"audio_username":`...)
+		dst = appendJSONString(dst, s.AudioUsername)
+	}
+
+	if len(s.Transcriptions) > 0 {
+		dst = append(dst, `,"transcriptions":[`...)
+
+		for i, transcription := range s.Transcriptions {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+
+			dst = append(dst, `{"script_name":`...)
+			dst = appendJSONString(dst, transcription.ScriptName)
+			dst = append(dst, `,"username":`...)
+			dst = appendJSONString(dst, transcription.Username)
+			dst = append(dst, `,"transcription":`...)
+			dst = appendJSONString(dst, transcription.Transcription)
+			dst = append(dst, '}')
+		}
+
+		dst = append(dst, ']')
+	}
+
+	return append(dst, '}')
+}
+
+// appendJSONIDs append a JSON array of sentence IDs.
+func appendJSONIDs(dst []byte, IDs []int32) []byte {
+	if IDs == nil {
+		return append(dst, "null"...)
+	}
+
+	dst = append(dst, '[')
+
+	for i, ID := range IDs {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+
+		dst = strconv.AppendInt(dst, int64(ID), 10)
+	}
+
+	return append(dst, ']')
+}
+
+// appendJSONString append a JSON string escaped like `json.Marshal` does,
+// including the HTML characters and the invalid UTF-8 sequences.
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			// Keep the safe ASCII characters as is.
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+
+			dst = append(dst, s[start:i]...)
+
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+
+		// Replace the invalid UTF-8 sequences.
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+
+		// Escape the line and paragraph separators for JavaScript.
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+
+		i += size
+	}
+
+	dst = append(dst, s[start:]...)
+
+	return append(dst, '"')
+}
@@ -0,0 +1,75 @@
+package tatoeba
+
+import (
+	json2 "encoding/json"
+	"testing"
+)
+
+// benchmarkSentence is a sentence with every field set.
+var benchmarkSentence = Sentence{
+	ID:                  1276,
+	Language:            "eng",
+	Content:             "Let's try something.",
+	Username:            "CK",
+	AddedAt:             "2010-08-11 02:07:43",
+	UpdatedAt:           "2020-04-29 18:33:22",
+	DirectRelations:     []int32{1277, 4724, 77921, 403357},
+	IndirectRelations:   []int32{1603, 2404, 93152},
+	TranslatedLanguages: []string{"fra", "deu", "jpn", "cmn"},
+	AudioUsername:       "CK",
+	Transcriptions: []Transcription{
+		{ScriptName: "Hrkt", Username: "", Transcription: "[何|なに]か[試|ため]してみよう。"},
+	},
+}
+
+func TestAppendJSON(t *testing.T) {
+	sentences := []Sentence{
+		benchmarkSentence,
+		{ID: 1, Language: "fra", Content: "Tab\tnew line\n \"quotes\" \\ <b>&</b> \b\f\x01 \u2028\u2029 \xff end"},
+		{ID: 2, Language: "jpn", Content: "", DirectRelations: []int32{}, TranslatedLanguages: []string{}},
+	}
+
+	for _, sentence := range sentences {
+		expected, err := json2.Marshal(sentence)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := sentence.AppendJSON(nil); string(actual) != string(expected) {
+			t.Errorf("AppendJSON() = %s, want %s", actual, expected)
+		}
+	}
+}
+
+// BenchmarkSentenceMapRoundTrip measures the previous encoding,
+// through a map of interfaces encoded again by the client.
+func BenchmarkSentenceMapRoundTrip(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var sentenceInterface map[string]interface{}
+
+		sentenceAsJSON, _ := json2.Marshal(benchmarkSentence)
+		_ = json2.Unmarshal(sentenceAsJSON, &sentenceInterface)
+		_, _ = json2.Marshal([]map[string]interface{}{sentenceInterface})
+	}
+}
+
+func BenchmarkSentenceMarshal(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		_, _ = json2.Marshal(benchmarkSentence)
+	}
+}
+
+func BenchmarkSentenceAppendJSON(b *testing.B) {
+	b.ReportAllocs()
+
+	var buffer []byte
+
+	for i := 0; i < b.N; i++ {
+		buffer = benchmarkSentence.AppendJSON(buffer[:0])
+	}
+}
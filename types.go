@@ -1,30 +1,18 @@
 package main
 
-// Sentence describes the fields to index.
-type Sentence struct {
-	ID                  int32           `json:"id"`
-	Language            string          `json:"language"`
-	Content             string          `json:"content"`
-	Username            string          `json:"username"`
-	AddedAt             string          `json:"added_at"`
-	UpdatedAt           string          `json:"updated_at"`
-	DirectRelations     []int32         `json:"direct_translations"`
-	IndirectRelations   []int32         `json:"indirect_translations"`
-	TranslatedLanguages []string        `json:"translated_languages"`
-	AudioUsername       string          `json:"audio_username,omitempty"`
-	Transcriptions      []Transcription `json:"transcriptions,omitempty"`
-}
+import "tatoeba-indexer/tatoeba"
 
-// Transcription describes the fields used to simplify
-// reading languages like Chinese, Cantonese or Japanese.
-type Transcription struct {
-	ScriptName    string `json:"script_name"`
-	Username      string `json:"username"`
-	Transcription string `json:"transcription"`
-}
-
-// Indexer define the methods indexers need to implement.
-type Indexer interface {
-	Init()
-	Index(map[string]Sentence)
-}
+// The types are defined by the tatoeba package,
+// so the CLI and the library share them.
+type (
+	// Sentence describes the fields to index.
+	Sentence = tatoeba.Sentence
+	// Transcription describes the transcription of a sentence.
+	Transcription = tatoeba.Transcription
+	// Indexer define the methods indexers need to implement.
+	Indexer = tatoeba.Indexer
+	// DeltaIndexer define the methods to only index the changed sentences.
+	DeltaIndexer = tatoeba.DeltaIndexer
+	// Verifier define the methods to verify the sentences have been indexed.
+	Verifier = tatoeba.Verifier
+)